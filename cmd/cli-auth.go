@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	auth "jabberwocky238/combinator/core/auth"
+	common "jabberwocky238/combinator/core/common"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	authKeyName   string
+	authKeyScopes []string
+)
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "管理网关认证",
+}
+
+var authKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "签发一个新的 API key",
+	Long: `签发一个新的 bearer API key，并输出可直接放入配置文件 auth.keys 的条目。
+明文 key 只会显示一次，配置文件中仅保存其 sha256 摘要。
+
+scope 示例: rdb:app:query, kv:cache:*, s3:*:get, admin:reload, *`,
+	Run: runAuthKeygen,
+}
+
+func init() {
+	authKeygenCmd.Flags().StringVarP(&authKeyName, "name", "n", "", "key 名称")
+	authKeygenCmd.Flags().StringSliceVarP(&authKeyScopes, "scope", "s", nil, "授权范围，可重复指定")
+	authCmd.AddCommand(authKeygenCmd)
+}
+
+func runAuthKeygen(cmd *cobra.Command, args []string) {
+	if len(authKeyScopes) == 0 {
+		fmt.Println("Error: at least one --scope is required")
+		os.Exit(1)
+	}
+	if _, err := auth.ParseScopes(authKeyScopes); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	key, hash, err := auth.IssueKey()
+	if err != nil {
+		fmt.Printf("Error generating key: %v\n", err)
+		os.Exit(1)
+	}
+
+	entry, err := json.MarshalIndent(common.APIKeyConfig{
+		Name:   authKeyName,
+		Hash:   hash,
+		Scopes: authKeyScopes,
+	}, "", "  ")
+	if err != nil {
+		fmt.Printf("Error creating config entry: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("API key (只显示一次): %s\n\n", key)
+	fmt.Println("添加到配置文件 auth.keys:")
+	fmt.Println(string(entry))
+}
@@ -59,7 +59,7 @@ func saveGlobalConfig(config *GlobalConfig) error {
 
 var configCmd = &cobra.Command{
 	Use:   "config",
-	Short: "管理全局配置 (~/.combinator/config.json)",
+	Short: "管理全局配置 (~/.combinator/config.json) 与网关配置历史",
 }
 
 var configInitCmd = &cobra.Command{
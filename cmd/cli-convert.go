@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	common "jabberwocky238/combinator/core/common"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	convertFrom string
+	convertTo   string
+)
+
+var configConvertCmd = &cobra.Command{
+	Use:   "convert <input> [output]",
+	Short: "在 JSON、YAML、TOML 之间转换网关配置文件",
+	Long: `在 JSON、YAML、TOML 之间转换网关配置文件。
+
+格式默认按扩展名判断（.json、.yaml / .yml、.toml），也可以用 --from / --to 指定；
+不指定 output 时输出到 stdout，此时需要 --to。
+${...} 引用与只写 ID 的实例保持原样；注释不会保留。`,
+	Args: cobra.RangeArgs(1, 2),
+	Run:  runConfigConvert,
+}
+
+func init() {
+	configConvertCmd.Flags().StringVar(&convertFrom, "from", "", "输入格式: json, yaml, toml；默认按扩展名判断")
+	configConvertCmd.Flags().StringVar(&convertTo, "to", "", "输出格式: json, yaml, toml；默认按扩展名判断")
+
+	configCmd.AddCommand(configConvertCmd)
+}
+
+func runConfigConvert(cmd *cobra.Command, args []string) {
+	input := args[0]
+	from, err := convertFormat(convertFrom, input)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	output := ""
+	if len(args) == 2 {
+		output = args[1]
+	} else if convertTo == "" {
+		fmt.Println("Error: --to is required when writing to stdout")
+		os.Exit(1)
+	}
+	to, err := convertFormat(convertTo, output)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	data, err := os.ReadFile(input)
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", input, err)
+		os.Exit(1)
+	}
+	conf, err := common.DecodeConfig(data, from)
+	if err != nil {
+		fmt.Printf("Error parsing %s as %s: %v\n", input, from, err)
+		os.Exit(1)
+	}
+
+	if output == "" {
+		buf, err := common.EncodeConfig(conf, to)
+		if err != nil {
+			fmt.Printf("Error encoding config: %v\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(buf)
+		return
+	}
+	if err := common.WriteConfigFileAs(output, conf, to); err != nil {
+		fmt.Printf("Error writing %s: %v\n", output, err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Converted %s (%s) -> %s (%s)\n", input, from, output, to)
+}
+
+// convertFormat 参数优先，否则按扩展名判断
+func convertFormat(flag, path string) (common.ConfigFormat, error) {
+	if flag != "" {
+		return common.ParseConfigFormat(flag)
+	}
+	return common.ConfigFormatFromPath(path), nil
+}
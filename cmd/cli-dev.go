@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"os/signal"
@@ -16,8 +15,9 @@ import (
 )
 
 var (
-	devConfigPath string
-	devListenAddr string
+	devConfigPath      string
+	devListenAddr      string
+	devShutdownTimeout int
 )
 
 var devCmd = &cobra.Command{
@@ -52,6 +52,10 @@ var devListRdbCmd = &cobra.Command{
 func init() {
 	devCmd.Flags().StringVarP(&devConfigPath, "config", "c", "config.combinator.json", "配置文件路径")
 	devCmd.Flags().StringVarP(&devListenAddr, "listen", "l", "localhost:8899", "监听地址")
+	devCmd.Flags().IntVar(&devShutdownTimeout, "shutdown-timeout", 5, "优雅关闭时等待进行中请求的最长时间（秒）")
+	addFormatFlag(devCmd)
+	addTraceFlags(devCmd)
+	addLogFlags(devCmd, "debug")
 
 	devClearCmd.AddCommand(devClearRdbCmd)
 	devListCmd.AddCommand(devListRdbCmd)
@@ -60,16 +64,15 @@ func init() {
 }
 
 func runDev(cmd *cobra.Command, args []string) {
-	// 加载配置文件
-	configJSON, err := os.ReadFile(devConfigPath)
-	if err != nil {
-		fmt.Printf("Failed to read config file: %v\n", err)
+	if err := setupLogging(); err != nil {
+		fmt.Printf("Failed to setup logging: %v\n", err)
 		return
 	}
 
-	var config common.Config
-	if err := json.Unmarshal(configJSON, &config); err != nil {
-		fmt.Printf("Failed to parse config file: %v\n", err)
+	// 加载配置文件
+	config, _, err := readConfigFile(devConfigPath)
+	if err != nil {
+		fmt.Printf("Failed to load config: %v\n", err)
 		return
 	}
 
@@ -92,19 +95,34 @@ func runDev(cmd *cobra.Command, args []string) {
 		oldURL := config.Rdb[i].URL
 		sqlitePath := filepath.Join(rdbDir, config.Rdb[i].ID+".sqlite")
 		config.Rdb[i].URL = "sqlite://" + sqlitePath
-		fmt.Printf("  ✓ RDB[%s]: %s -> sqlite://%s\n", config.Rdb[i].ID, oldURL, sqlitePath)
+		fmt.Printf("  ✓ RDB[%s]: %s -> sqlite://%s\n", config.Rdb[i].ID, common.RedactURL(oldURL), sqlitePath)
 	}
 
 	// 转换所有 KV 为内存模式
 	for i := range config.Kv {
 		oldURL := config.Kv[i].URL
 		config.Kv[i].URL = "memory://"
-		fmt.Printf("  ✓ KV[%s]: %s -> memory://\n", config.Kv[i].ID, oldURL)
+		fmt.Printf("  ✓ KV[%s]: %s -> memory://\n", config.Kv[i].ID, common.RedactURL(oldURL))
+	}
+
+	// 转换所有 S3 为本地存储
+	s3Dir := filepath.Join(home, ".combinator", "s3")
+	for i := range config.S3 {
+		oldURL := config.S3[i].URL
+		localPath := filepath.Join(s3Dir, config.S3[i].ID)
+		config.S3[i].URL = "local://" + localPath
+		fmt.Printf("  ✓ S3[%s]: %s -> local://%s\n", config.S3[i].ID, common.RedactURL(oldURL), localPath)
+	}
+
+	if err := setupTracing(); err != nil {
+		fmt.Printf("Failed to setup tracing: %v\n", err)
+		return
 	}
 
 	// 启动网关
-	gateway := combinator.NewGateway(&config, true)
+	gateway := combinator.NewGateway(config, true)
 	gateway.SetupMonitorAPI()
+	gateway.SetupMetricsAPI()
 
 	// 启动信号监听
 	sigChan := make(chan os.Signal, 1)
@@ -122,6 +140,7 @@ func runDev(cmd *cobra.Command, args []string) {
 	// 阻塞等待 Ctrl+C
 	<-sigChan
 	fmt.Println("\n✓ Received interrupt signal, shutting down gracefully...")
+	shutdownGateway(gateway, devShutdownTimeout)
 }
 
 func getRdbDir() (string, error) {
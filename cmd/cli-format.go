@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	common "jabberwocky238/combinator/core/common"
+
+	"github.com/spf13/cobra"
+)
+
+var configFormatFlag string
+
+// addFormatFlag 为读写网关配置文件的命令注册相同的 --format 参数
+func addFormatFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&configFormatFlag, "format", "", "配置文件格式: json, yaml, toml；默认按扩展名判断")
+}
+
+// configFormat --format 优先，否则按扩展名判断
+func configFormat(path string) (common.ConfigFormat, error) {
+	if configFormatFlag != "" {
+		return common.ParseConfigFormat(configFormatFlag)
+	}
+	return common.ConfigFormatFromPath(path), nil
+}
+
+// readConfigFile 读取任意格式的网关配置，同时返回文件内容用于计算哈希
+func readConfigFile(path string) (*common.Config, []byte, error) {
+	format, err := configFormat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	conf, err := common.DecodeConfig(data, format)
+	if err != nil {
+		return nil, data, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return conf, data, nil
+}
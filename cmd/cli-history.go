@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	combinator "jabberwocky238/combinator/core"
+	common "jabberwocky238/combinator/core/common"
+	history "jabberwocky238/combinator/core/history"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyConfigPath string
+	historyLimit      int
+	historyJSON       bool
+	rollbackServer    string
+	rollbackToken     string
+)
+
+var configHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "查看网关配置的修订历史",
+	Run:   runConfigHistory,
+}
+
+var configRollbackCmd = &cobra.Command{
+	Use:   "rollback <rev>",
+	Short: "回滚网关配置到指定修订",
+	Long: `回滚网关配置到指定修订。
+
+默认直接把该修订的配置写回配置文件，使用 --watch file 运行的网关会自动重载；
+指定 --server 时通过运行中网关的 /monitor 接口立即回滚。`,
+	Args: cobra.ExactArgs(1),
+	Run:  runConfigRollback,
+}
+
+func init() {
+	for _, c := range []*cobra.Command{configHistoryCmd, configRollbackCmd} {
+		c.Flags().StringVarP(&historyConfigPath, "config", "c", "config.combinator.json", "网关配置文件路径")
+	}
+	configHistoryCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "最多显示的修订数量，0 表示全部")
+	configHistoryCmd.Flags().BoolVar(&historyJSON, "json", false, "以 JSON 输出")
+	configRollbackCmd.Flags().StringVar(&rollbackServer, "server", "", "运行中网关的地址，例如 http://localhost:8899")
+	configRollbackCmd.Flags().StringVar(&rollbackToken, "token", "", "调用 /monitor 使用的 bearer token")
+	addFormatFlag(configRollbackCmd)
+
+	configCmd.AddCommand(configHistoryCmd)
+	configCmd.AddCommand(configRollbackCmd)
+}
+
+// historyPath 每个网关配置文件对应 ~/.combinator/history 下的一个历史文件
+func historyPath(configFile string) (string, error) {
+	abs, err := filepath.Abs(configFile)
+	if err != nil {
+		return "", err
+	}
+	dir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	name := strings.TrimSuffix(filepath.Base(abs), filepath.Ext(abs))
+	return filepath.Join(dir, "history", name+"-"+hex.EncodeToString(sum[:4])+".jsonl"), nil
+}
+
+func openHistory(configFile string) (*history.Store, error) {
+	path, err := historyPath(configFile)
+	if err != nil {
+		return nil, err
+	}
+	return history.Open(path, history.DefaultLimit)
+}
+
+func runConfigHistory(cmd *cobra.Command, args []string) {
+	store, err := openHistory(historyConfigPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	revs := store.List()
+	if historyLimit > 0 && len(revs) > historyLimit {
+		revs = revs[len(revs)-historyLimit:]
+	}
+
+	if historyJSON {
+		// 历史中保存的是原始配置（用于回滚），输出前隐藏密码与 API key
+		for i := range revs {
+			revs[i].Config = common.RedactConfig(revs[i].Config)
+		}
+		data, _ := json.MarshalIndent(revs, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	if len(revs) == 0 {
+		fmt.Printf("没有修订历史: %s\n", store.Path())
+		return
+	}
+	for i := len(revs) - 1; i >= 0; i-- {
+		r := revs[i]
+		status := "applied"
+		if !r.Applied {
+			status = "failed"
+		}
+		fmt.Printf("#%-4d %s  %-12s %s\n", r.Rev, r.Time.Local().Format(time.DateTime), r.Source, status)
+		if r.Error != "" {
+			fmt.Printf("      error: %s\n", r.Error)
+		}
+		for _, c := range r.Diff {
+			fmt.Printf("      %s\n", formatChange(c))
+		}
+	}
+}
+
+func formatChange(c history.Change) string {
+	target := c.Section
+	if c.ID != "" {
+		target = fmt.Sprintf("%s[%s]", strings.ToUpper(c.Section), c.ID)
+	}
+	switch {
+	case c.Op == history.OpChanged && c.Before != c.After:
+		return fmt.Sprintf("~ %s: %s -> %s", target, c.Before, c.After)
+	case c.Op == history.OpChanged:
+		return fmt.Sprintf("~ %s", target)
+	case c.Op == history.OpAdded:
+		return strings.TrimSpace(fmt.Sprintf("+ %s %s", target, c.After))
+	default:
+		return strings.TrimSpace(fmt.Sprintf("- %s %s", target, c.Before))
+	}
+}
+
+func runConfigRollback(cmd *cobra.Command, args []string) {
+	rev, err := strconv.Atoi(args[0])
+	if err != nil || rev <= 0 {
+		fmt.Printf("无效的修订号: %s\n", args[0])
+		os.Exit(1)
+	}
+
+	if rollbackServer != "" {
+		rollbackRemote(rev)
+		return
+	}
+
+	store, err := openHistory(historyConfigPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	target, ok := store.Get(rev)
+	if !ok {
+		fmt.Printf("修订不存在: %d\n", rev)
+		os.Exit(1)
+	}
+
+	format, err := configFormat(historyConfigPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := common.WriteConfigFileAs(historyConfigPath, target.Config, format); err != nil {
+		fmt.Printf("写入配置文件失败: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ 已将修订 #%d 写入 %s\n", rev, historyConfigPath)
+	fmt.Println("  使用 --watch file 运行的网关会自动重载，否则请重启或调用 /reload")
+}
+
+// rollbackRemote 调用运行中网关的 config.rollback
+func rollbackRemote(rev int) {
+	body, _ := json.Marshal(combinator.JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "config.rollback",
+		Params:  json.RawMessage(fmt.Sprintf(`{"rev":%d}`, rev)),
+		ID:      1,
+	})
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(rollbackServer, "/")+"/monitor", bytes.NewReader(body))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if rollbackToken != "" {
+		req.Header.Set("Authorization", "Bearer "+rollbackToken)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Printf("请求失败: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Printf("请求失败: %s %s\n", resp.Status, strings.TrimSpace(string(data)))
+		os.Exit(1)
+	}
+
+	var rpcResp struct {
+		Result *common.ReloadResult `json:"result"`
+		Error  *combinator.RPCError `json:"error"`
+	}
+	if err := json.Unmarshal(data, &rpcResp); err != nil {
+		fmt.Printf("无效的响应: %v\n", err)
+		os.Exit(1)
+	}
+	if rpcResp.Error != nil {
+		fmt.Printf("❌ 回滚失败: %s\n", rpcResp.Error.Message)
+		if detail, err := json.Marshal(rpcResp.Error.Data); err == nil && rpcResp.Error.Data != nil {
+			var result common.ReloadResult
+			if json.Unmarshal(detail, &result) == nil {
+				printReloadResult(&result)
+			}
+		}
+		os.Exit(1)
+	}
+	fmt.Printf("✓ 已回滚到修订 #%d\n", rev)
+	if rpcResp.Result != nil {
+		printReloadResult(rpcResp.Result)
+	}
+}
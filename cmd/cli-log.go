@@ -0,0 +1,33 @@
+package main
+
+import (
+	common "jabberwocky238/combinator/core/common"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cobra"
+)
+
+var (
+	logFormat string
+	logLevel  string
+)
+
+// addLogFlags 为 start / dev 注册相同的日志参数
+func addLogFlags(cmd *cobra.Command, defaultLevel string) {
+	cmd.Flags().StringVar(&logFormat, "log-format", "text", "日志格式: text, json")
+	cmd.Flags().StringVar(&logLevel, "log-level", defaultLevel, "日志级别: debug, info, warn, error；SQL 语句只在 debug 级别输出")
+}
+
+func setupLogging() error {
+	if err := common.SetLogFormat(logFormat); err != nil {
+		return err
+	}
+	if err := common.SetLogLevel(logLevel); err != nil {
+		return err
+	}
+	// gin 的调试输出不是结构化日志，只在 text + debug 时保留
+	if logFormat != "text" || logLevel != "debug" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	return nil
+}
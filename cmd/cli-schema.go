@@ -0,0 +1,48 @@
+package main
+
+//go:generate go run . config schema -o ../config.schema.json
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	common "jabberwocky238/combinator/core/common"
+
+	"github.com/spf13/cobra"
+)
+
+var schemaOutput string
+
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "输出网关配置文件的 JSON Schema",
+	Long: `根据配置的 Go 类型生成 JSON Schema，可在配置文件中通过 "$schema" 引用以获得编辑器补全。
+仓库根目录的 config.schema.json 由 go generate ./cmd 生成。`,
+	Run: runConfigSchema,
+}
+
+func init() {
+	configSchemaCmd.Flags().StringVarP(&schemaOutput, "output", "o", "", "写入文件，默认输出到 stdout")
+
+	configCmd.AddCommand(configSchemaCmd)
+}
+
+func runConfigSchema(cmd *cobra.Command, args []string) {
+	data, err := json.MarshalIndent(common.ConfigSchema(), "", "  ")
+	if err != nil {
+		fmt.Printf("Error generating schema: %v\n", err)
+		os.Exit(1)
+	}
+	data = append(data, '\n')
+
+	if schemaOutput == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(schemaOutput, data, 0644); err != nil {
+		fmt.Printf("Error writing schema: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Schema written: %s\n", schemaOutput)
+}
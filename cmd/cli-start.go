@@ -1,15 +1,17 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
-	"encoding/json"
 	"fmt"
 	"time"
 
 	combinator "jabberwocky238/combinator/core"
 	common "jabberwocky238/combinator/core/common"
+	trace "jabberwocky238/combinator/core/trace"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/spf13/cobra"
@@ -22,7 +24,13 @@ var (
 	listenAddr       string
 	watchMode        string
 	watchInterval    int
-	lastHash         [32]byte
+	watchDebounce    int
+	tlsCertFile      string
+	tlsKeyFile       string
+	tlsClientCAFile  string
+	shutdownTimeout  int
+	healthInterval   int
+	healthTimeout    int
 	startCmdInstance StartCmd
 )
 
@@ -36,76 +44,148 @@ func init() {
 	startCmd.Flags().StringVarP(&configPath, "config", "c", "config.combinator.json", "配置文件路径")
 	startCmd.Flags().StringVarP(&listenAddr, "listen", "l", "localhost:8899", "监听地址")
 	startCmd.Flags().StringVarP(&watchMode, "watch", "w", "", "配置监听模式: file, api, all")
-	startCmd.Flags().IntVar(&watchInterval, "watch-interval", 5, "文件监听间隔（秒）")
+	startCmd.Flags().IntVar(&watchInterval, "watch-interval", 5, "TLS 证书检查间隔（秒）；文件事件不可用时也按该间隔轮询配置文件")
+	startCmd.Flags().IntVar(&watchDebounce, "watch-debounce", 300, "配置文件变化后等待多久再重载（毫秒），期间的连续修改合并为一次")
+	startCmd.Flags().StringVar(&tlsCertFile, "tls-cert", "", "TLS 证书文件路径")
+	startCmd.Flags().StringVar(&tlsKeyFile, "tls-key", "", "TLS 私钥文件路径")
+	startCmd.Flags().StringVar(&tlsClientCAFile, "tls-client-ca", "", "客户端 CA 证书路径，设置后启用双向 TLS")
+	startCmd.Flags().IntVar(&shutdownTimeout, "shutdown-timeout", 30, "优雅关闭时等待进行中请求的最长时间（秒）")
+	startCmd.Flags().IntVar(&healthInterval, "health-interval", 15, "后台健康探测间隔（秒），0 表示关闭")
+	startCmd.Flags().IntVar(&healthTimeout, "health-timeout", 3, "单个实例健康探测超时（秒）")
+	addFormatFlag(startCmd)
+	addTraceFlags(startCmd)
+	addLogFlags(startCmd, "info")
 }
 
-// 加载配置文件
-func (s *StartCmd) loadConfig(path string) (*common.Config, [32]byte, error) {
-	configJSON, err := os.ReadFile(path)
-	if err != nil {
-		return nil, [32]byte{}, fmt.Errorf("failed to read config file: %w", err)
+// 打印每个实例的重载结果
+func printReloadResult(result *common.ReloadResult) {
+	for _, inst := range result.Instances {
+		if inst.Error != "" {
+			fmt.Printf("  ✗ %s[%s]: %s (%s)\n", strings.ToUpper(inst.Kind), inst.ID, inst.Action, inst.Error)
+			continue
+		}
+		fmt.Printf("  ✓ %s[%s]: %s\n", strings.ToUpper(inst.Kind), inst.ID, inst.Action)
 	}
+}
 
-	newHash := sha256.Sum256(configJSON)
-	var config common.Config
-	if err := json.Unmarshal(configJSON, &config); err != nil {
-		return nil, newHash, fmt.Errorf("failed to parse config file: %w", err)
-	}
+// 优雅关闭 gateway，等待进行中的请求后关闭所有后端
+func shutdownGateway(gateway *combinator.Gateway, timeout int) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
 
-	return &config, newHash, nil
+	err := gateway.Shutdown(ctx)
+	// 导出剩余的 span
+	if terr := trace.Shutdown(ctx); terr != nil {
+		fmt.Printf("⚠️  Failed to flush traces: %v\n", terr)
+	}
+	if err != nil {
+		fmt.Printf("❌ Shutdown finished with errors:\n%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("✓ All services closed")
 }
 
-// 文件监听
-func (s *StartCmd) watchConfigFile(path string, interval int, reloadChan chan<- *common.Config) {
-	ticker := time.NewTicker(time.Duration(interval) * time.Second)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		// 直接读文件内容以避免某些文件系统不更新修改时间的问题
-		config, newHash, err := s.loadConfig(path)
-		if err != nil {
-			fmt.Printf("⚠️  Failed to read config file: %v\n", err)
-			continue
-		}
+// 加载配置文件
+func (s *StartCmd) loadConfig(path string) (*common.Config, [32]byte, error) {
+	config, data, err := readConfigFile(path)
+	if data == nil {
+		return nil, [32]byte{}, err
+	}
 
-		if newHash == lastHash {
-			continue // 文件内容未变更
-		} else {
-			fmt.Println("📝 Config file changed, reloading...")
-			reloadChan <- config
-		}
+	// 哈希的是文件原始内容，与格式无关
+	newHash := sha256.Sum256(data)
+	if err != nil {
+		return nil, newHash, err
+	}
+	// 只检查 ${...} 引用能否解析，展开后的配置不离开 gateway，避免密钥被写回文件或历史
+	if _, err := common.ExpandConfig(config); err != nil {
+		return nil, newHash, fmt.Errorf("failed to resolve config: %w", err)
 	}
+
+	return config, newHash, nil
 }
 
 func (s *StartCmd) runStart(cmd *cobra.Command, args []string) {
+	if err := setupLogging(); err != nil {
+		fmt.Printf("Failed to setup logging: %v\n", err)
+		return
+	}
+
 	// 加载初始配置
 	config, newHash, err := s.loadConfig(configPath)
 	if err != nil {
 		fmt.Printf("Failed to load config: %v\n", err)
 		return
 	}
-	lastHash = newHash
+
+	if err := setupTracing(); err != nil {
+		fmt.Printf("Failed to setup tracing: %v\n", err)
+		return
+	}
 
 	// 创建并启动 gateway
 	gateway := combinator.NewGateway(config, false)
 
+	if tlsCertFile != "" || tlsKeyFile != "" || tlsClientCAFile != "" {
+		err := gateway.EnableTLS(combinator.TLSOptions{
+			CertFile:     tlsCertFile,
+			KeyFile:      tlsKeyFile,
+			ClientCAFile: tlsClientCAFile,
+		})
+		if err != nil {
+			fmt.Printf("Failed to load TLS certificate: %v\n", err)
+			return
+		}
+		if tlsClientCAFile != "" {
+			fmt.Println("🔒 Mutual TLS enabled")
+		} else {
+			fmt.Println("🔒 TLS enabled")
+		}
+	}
+
+	// 修订历史，用于 config history / rollback
+	store, err := openHistory(configPath)
+	if err != nil {
+		fmt.Printf("Failed to open config history: %v\n", err)
+		return
+	}
+	gateway.EnableHistory(store)
+	// admin 接口与回滚的修改写回配置文件
+	format, _ := configFormat(configPath) // loadConfig 已经检查过
+	gateway.SetConfigFile(configPath, format)
+
+	gateway.SetHealthCheck(time.Duration(healthInterval)*time.Second, time.Duration(healthTimeout)*time.Second)
+	gateway.SetupMonitorAPI()
+	gateway.SetupMetricsAPI()
+
 	// 配置重载通道
-	reloadChan := make(chan *common.Config, 1)
+	reloadChan := make(chan common.ReloadRequest, 1)
+
+	// SIGHUP 总是重新读取配置文件与 TLS 证书
+	watcher, err := newConfigWatcher(configPath, newHash, time.Duration(watchDebounce)*time.Millisecond, gateway, reloadChan)
+	if err != nil {
+		fmt.Printf("Failed to watch config file: %v\n", err)
+		return
+	}
 
 	// 启动 watch 模式
 	if watchMode == "file" || watchMode == "all" {
-		fmt.Printf("📁 File watch enabled (interval: %ds)\n", watchInterval)
-		go s.watchConfigFile(configPath, watchInterval, reloadChan)
+		fmt.Printf("📁 File watch enabled (debounce: %dms)\n", watchDebounce)
+		go watcher.watch(time.Duration(watchInterval) * time.Second)
 	}
 
 	if watchMode == "api" || watchMode == "all" {
 		fmt.Println("🌐 API reload endpoint enabled at /reload")
 		gateway.SetupReloadAPI(reloadChan)
+		fmt.Println("🛠  Admin API enabled at /admin/{rdb,kv,s3}/:id")
+		gateway.SetupAdminAPI()
 	}
 
 	// 启动信号监听
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
 
 	// 在 goroutine 中启动 gateway
 	go func() {
@@ -121,15 +201,25 @@ func (s *StartCmd) runStart(cmd *cobra.Command, args []string) {
 		select {
 		case <-sigChan:
 			fmt.Println("\n✓ Received interrupt signal, shutting down gracefully...")
+			shutdownGateway(gateway, shutdownTimeout)
 			return
-		case newConfig := <-reloadChan:
+		case <-hupChan:
+			fmt.Println("🔄 Received SIGHUP, reloading configuration and TLS certificate...")
+			if err := gateway.ReloadTLS(); err != nil {
+				fmt.Printf("⚠️  Failed to reload TLS certificate: %v\n", err)
+			}
+			// 即使文件未变也重新加载，${ENV} / ${file:} 引用的密钥可能已经轮换
+			go watcher.reloadFile("signal", true)
+		case req := <-reloadChan:
 			fmt.Println("✅ Reloading gateway with new configuration...")
-			buf, err := json.Marshal(newConfig)
+			result, err := gateway.Reload(req.Config, req.Source)
+			printReloadResult(result)
 			if err != nil {
-				fmt.Printf("❌ Failed to print new config: %v\n", err)
+				fmt.Printf("❌ Reload failed, keeping previous configuration: %v\n", err)
+			}
+			if req.Result != nil {
+				req.Result <- result
 			}
-			lastHash = sha256.Sum256(buf)
-			gateway.Reload(newConfig)
 		}
 	}
 }
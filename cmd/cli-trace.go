@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	trace "jabberwocky238/combinator/core/trace"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	traceExporter string
+	traceEndpoint string
+	traceHeaders  []string
+	traceFile     string
+)
+
+// addTraceFlags 为 start / dev 注册相同的追踪参数
+func addTraceFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&traceExporter, "trace-exporter", "none", "追踪导出方式: none, otlp, file")
+	cmd.Flags().StringVar(&traceEndpoint, "trace-endpoint", "http://localhost:4318/v1/traces", "OTLP/HTTP traces 地址")
+	cmd.Flags().StringSliceVar(&traceHeaders, "trace-header", nil, "OTLP 请求头 key=value，可重复指定")
+	cmd.Flags().StringVar(&traceFile, "trace-file", "traces.jsonl", "file 导出方式的输出文件")
+}
+
+func setupTracing() error {
+	switch traceExporter {
+	case "", "none":
+		return nil
+	case "otlp":
+		headers := make(map[string]string)
+		for _, h := range traceHeaders {
+			k, v, ok := strings.Cut(h, "=")
+			if !ok {
+				return fmt.Errorf("invalid --trace-header %q, expected key=value", h)
+			}
+			headers[k] = v
+		}
+		trace.Init(trace.NewOTLPExporter(traceEndpoint, headers, "combinator"))
+		fmt.Printf("🔭 Tracing enabled, exporting to %s\n", traceEndpoint)
+	case "file":
+		exp, err := trace.NewFileExporter(traceFile)
+		if err != nil {
+			return fmt.Errorf("failed to open trace file: %w", err)
+		}
+		trace.Init(exp)
+		fmt.Printf("🔭 Tracing enabled, writing to %s\n", traceFile)
+	default:
+		return fmt.Errorf("unknown trace exporter: %s", traceExporter)
+	}
+	return nil
+}
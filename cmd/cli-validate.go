@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	combinator "jabberwocky238/combinator/core"
+	common "jabberwocky238/combinator/core/common"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	validateConfigPath string
+	validateConnect    bool
+	validateTimeout    int
+)
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "检查网关配置文件",
+	Long: `检查网关配置文件，以 JSON 输出检查报告，有任何错误时以非零状态退出。
+
+静态检查包括：文件格式（JSON、YAML、TOML）与未知字段、${ENV} / ${file:} 引用、实例 ID 重复、
+URL 与 metadata 选项、后端类型是否已编译进来（例如 rocksdb 需要 -tags kv_rocksdb），
+以及 auth、rateLimit、circuitBreaker、timeouts、cors 各段。
+指定 --connect 时还会启动每个实例并 Ping，检查后立即关闭。`,
+	Run: runConfigValidate,
+}
+
+func init() {
+	configValidateCmd.Flags().StringVarP(&validateConfigPath, "config", "c", "config.combinator.json", "网关配置文件路径")
+	configValidateCmd.Flags().BoolVar(&validateConnect, "connect", false, "启动每个实例并检查连通性")
+	configValidateCmd.Flags().IntVar(&validateTimeout, "timeout", 10, "--connect 时单个实例的超时（秒）")
+	addFormatFlag(configValidateCmd)
+
+	configCmd.AddCommand(configValidateCmd)
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) {
+	// 日志只用于连接过程中的实例输出，报告写到 stdout
+	common.Logger.SetOutput(os.Stderr)
+	common.SetLogLevel("warn")
+
+	report := &combinator.ValidationReport{Errors: []string{}, Instances: []combinator.InstanceCheck{}}
+	conf, err := decodeConfigStrict(validateConfigPath)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	} else {
+		report = combinator.ValidateConfig(context.Background(), conf, combinator.ValidateOptions{
+			Connect: validateConnect,
+			Timeout: time.Duration(validateTimeout) * time.Second,
+		})
+	}
+
+	data, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(data))
+	if !report.Valid {
+		os.Exit(1)
+	}
+}
+
+// decodeConfigStrict 与 start 相同地读取配置，但未知字段视为错误，便于发现拼写错误
+func decodeConfigStrict(path string) (*common.Config, error) {
+	format, err := configFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if data, err = common.ConfigToJSON(data, format); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var conf common.Config
+	if err := dec.Decode(&conf); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return &conf, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	combinator "jabberwocky238/combinator/core"
+	common "jabberwocky238/combinator/core/common"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// configWatcher 监听配置文件的变化，检查通过后提交给重载循环。
+// 监听的是文件所在目录而不是文件本身，这样原子替换（写临时文件再 rename）
+// 以及 Kubernetes ConfigMap 的符号链接切换之后仍然有效
+type configWatcher struct {
+	path       string // 绝对路径
+	debounce   time.Duration
+	gateway    *combinator.Gateway
+	reloadChan chan<- common.ReloadRequest
+
+	mu       sync.Mutex
+	lastHash [32]byte // 最近一次读到的文件内容，与格式无关
+	realPath string   // 符号链接解析后的路径，ConfigMap 更新时会变化
+}
+
+func newConfigWatcher(path string, hash [32]byte, debounce time.Duration, gateway *combinator.Gateway, reloadChan chan<- common.ReloadRequest) (*configWatcher, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	realPath, _ := filepath.EvalSymlinks(abs)
+	return &configWatcher{
+		path:       abs,
+		debounce:   debounce,
+		gateway:    gateway,
+		reloadChan: reloadChan,
+		lastHash:   hash,
+		realPath:   realPath,
+	}, nil
+}
+
+// watch 处理文件事件，连续的事件在 debounce 窗口内合并为一次重载；
+// 每个 interval 检查一次 TLS 证书，文件事件不可用时同时轮询配置文件
+func (w *configWatcher) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	fw, err := w.notify()
+	if err != nil {
+		fmt.Printf("⚠️  File events unavailable (%v), polling every %s\n", err, interval)
+	} else {
+		defer fw.Close()
+		events, errs = fw.Events, fw.Errors
+	}
+
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if w.relevant(ev) {
+				timer.Reset(w.debounce)
+			}
+		case err, ok := <-errs:
+			if !ok {
+				return
+			}
+			fmt.Printf("⚠️  Config watch error: %v\n", err)
+		case <-timer.C:
+			w.reloadFile("file", false)
+		case <-ticker.C:
+			if err := w.gateway.ReloadTLS(); err != nil {
+				fmt.Printf("⚠️  Failed to reload TLS certificate: %v\n", err)
+			}
+			if fw == nil {
+				w.reloadFile("file", false)
+			}
+		}
+	}
+}
+
+func (w *configWatcher) notify() (*fsnotify.Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := fw.Add(filepath.Dir(w.path)); err != nil {
+		fw.Close()
+		return nil, err
+	}
+	return fw, nil
+}
+
+// relevant 配置文件本身被写入或创建（包括 rename 替换），或者符号链接指向了新文件
+func (w *configWatcher) relevant(ev fsnotify.Event) bool {
+	if filepath.Clean(ev.Name) == w.path && ev.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+		return true
+	}
+	realPath, _ := filepath.EvalSymlinks(w.path)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if realPath != "" && realPath != w.realPath {
+		w.realPath = realPath
+		return true
+	}
+	return false
+}
+
+// reloadFile 读取并检查配置文件，内容有变化（或 force）且检查通过时提交重载；
+// 检查失败时继续使用当前配置，同样的内容不会重复报告
+func (w *configWatcher) reloadFile(source string, force bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	config, newHash, err := startCmdInstance.loadConfig(w.path)
+	if newHash == ([32]byte{}) {
+		// 文件暂时不存在，例如编辑器先删除再写入
+		fmt.Printf("⚠️  Failed to read config file: %v\n", err)
+		return
+	}
+	if newHash == w.lastHash && !force {
+		return // 文件内容未变更
+	}
+	w.lastHash = newHash
+
+	if err == nil {
+		report := combinator.ValidateConfig(context.Background(), config, combinator.ValidateOptions{})
+		err = report.Err()
+	}
+	if err != nil {
+		fmt.Printf("❌ Config file is invalid, keeping current configuration:\n%v\n", err)
+		return
+	}
+
+	fmt.Printf("📝 Reloading config file (%s)...\n", source)
+	w.reloadChan <- common.ReloadRequest{Config: config, Source: source}
+}
@@ -17,6 +17,7 @@ func main() {
 	rootCmd.AddCommand(devCmd)
 	rootCmd.AddCommand(migrateCmd)
 	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(authCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		return
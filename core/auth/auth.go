@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+var (
+	ErrMissingToken = errors.New("missing bearer token")
+	ErrInvalidToken = errors.New("invalid bearer token")
+)
+
+// gin context 中保存认证结果的 key，后续中间件（行过滤、key 前缀等）可据此读取
+const (
+	ContextPrincipal = "auth_principal"
+	ContextClaims    = "auth_claims"
+)
+
+// keyPrefix 标识 combinator 签发的 API key
+const keyPrefix = "cmb_"
+
+// hashPrefix 配置文件中 hash 字段的前缀
+const hashPrefix = "sha256:"
+
+// Principal 表示一个通过认证的调用方
+// 通过 JWT 认证时 Claims 为 token 的全部 claims，供后续中间件使用
+type Principal struct {
+	Name   string
+	Scopes []Scope
+	Claims map[string]any
+}
+
+// Allows reports whether any of the principal's scopes grants the target
+func (p *Principal) Allows(kind, id, op string) bool {
+	for _, s := range p.Scopes {
+		if s.Match(kind, id, op) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator 校验 bearer token 并返回调用方身份
+type Authenticator struct {
+	keys map[string]*Principal // sha256 hex -> principal
+	jwt  *JWTVerifier
+}
+
+// New builds an Authenticator from config; nil config, no keys and no jwt disables auth
+func New(conf *common.AuthConfig) (*Authenticator, error) {
+	if conf == nil || (len(conf.Keys) == 0 && conf.JWT == nil) {
+		return nil, nil
+	}
+
+	a := &Authenticator{
+		keys: make(map[string]*Principal),
+	}
+	if conf.JWT != nil {
+		v, err := NewJWTVerifier(conf.JWT)
+		if err != nil {
+			return nil, fmt.Errorf("auth jwt: %w", err)
+		}
+		a.jwt = v
+	}
+	for i, k := range conf.Keys {
+		name := k.Name
+		if name == "" {
+			name = fmt.Sprintf("key#%d", i)
+		}
+
+		digest, err := keyDigest(k)
+		if err != nil {
+			return nil, fmt.Errorf("auth key %s: %w", name, err)
+		}
+		if _, dup := a.keys[digest]; dup {
+			return nil, fmt.Errorf("auth key %s: duplicate key", name)
+		}
+
+		scopes, err := ParseScopes(k.Scopes)
+		if err != nil {
+			return nil, fmt.Errorf("auth key %s: %w", name, err)
+		}
+
+		a.keys[digest] = &Principal{Name: name, Scopes: scopes}
+	}
+	return a, nil
+}
+
+// Authenticate validates a raw bearer token
+func (a *Authenticator) Authenticate(token string) (*Principal, error) {
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+	// 以 hash 作为 map key，比较的是摘要而不是原文
+	if p, ok := a.keys[HashKey(token)]; ok {
+		return p, nil
+	}
+	if a.jwt != nil && strings.Count(token, ".") == 2 {
+		return a.jwt.Verify(token)
+	}
+	return nil, ErrInvalidToken
+}
+
+// IssueKey generates a new random API key and its config hash
+func IssueKey() (key string, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	key = keyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return key, hashPrefix + HashKey(key), nil
+}
+
+// HashKey returns the hex sha256 digest of a key
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func keyDigest(k common.APIKeyConfig) (string, error) {
+	switch {
+	case k.Hash != "" && k.Key != "":
+		return "", fmt.Errorf("only one of key or hash may be set")
+	case k.Hash != "":
+		digest := strings.ToLower(strings.TrimPrefix(k.Hash, hashPrefix))
+		if raw, err := hex.DecodeString(digest); err != nil || len(raw) != sha256.Size {
+			return "", fmt.Errorf("invalid hash, expected %s<64 hex chars>", hashPrefix)
+		}
+		return digest, nil
+	case k.Key != "":
+		return HashKey(k.Key), nil
+	default:
+		return "", fmt.Errorf("key or hash is required")
+	}
+}
@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+// TestScopeMatch 测试 scope 解析与匹配
+func TestScopeMatch(t *testing.T) {
+	tests := []struct {
+		scope string
+		kind  string
+		id    string
+		op    string
+		want  bool
+	}{
+		{"rdb:app:query", "rdb", "app", "query", true},
+		{"rdb:app:query", "rdb", "app", "exec", false},
+		{"rdb:app:query", "rdb", "other", "query", false},
+		{"rdb:app:*", "rdb", "app", "batch", true},
+		{"kv:*:get", "kv", "cache", "get", true},
+		{"kv:*:get", "rdb", "cache", "get", false},
+		{"admin:reload", "admin", "", "reload", true},
+		{"admin:reload", "admin", "", "monitor", false},
+		{"*", "admin", "", "monitor", true},
+		{"*", "s3", "files", "delete", true},
+	}
+
+	for _, tt := range tests {
+		scope, err := ParseScope(tt.scope)
+		if err != nil {
+			t.Fatalf("ParseScope(%q) failed: %v", tt.scope, err)
+		}
+		if got := scope.Match(tt.kind, tt.id, tt.op); got != tt.want {
+			t.Errorf("%q.Match(%s, %s, %s) = %v, want %v", tt.scope, tt.kind, tt.id, tt.op, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "rdb:app", "admin:a:b", "queue:x:y", "rdb::query"} {
+		if _, err := ParseScope(bad); err == nil {
+			t.Errorf("ParseScope(%q) should fail", bad)
+		}
+	}
+}
+
+// TestAuthenticate 测试明文 key 与 hash key 的认证
+func TestAuthenticate(t *testing.T) {
+	key, hash, err := IssueKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := New(&common.AuthConfig{Keys: []common.APIKeyConfig{
+		{Name: "hashed", Hash: hash, Scopes: []string{"rdb:app:query"}},
+		{Name: "plain", Key: "local-dev-key", Scopes: []string{"*"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := a.Authenticate(key)
+	if err != nil || p.Name != "hashed" {
+		t.Fatalf("Authenticate(issued key) = %v, %v", p, err)
+	}
+	if !p.Allows("rdb", "app", "query") || p.Allows("rdb", "app", "exec") {
+		t.Errorf("unexpected scopes for %s", p.Name)
+	}
+
+	if p, err := a.Authenticate("local-dev-key"); err != nil || p.Name != "plain" {
+		t.Fatalf("Authenticate(plain key) = %v, %v", p, err)
+	}
+	if _, err := a.Authenticate("nope"); err != ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+	if _, err := a.Authenticate(""); err != ErrMissingToken {
+		t.Errorf("expected ErrMissingToken, got %v", err)
+	}
+
+	if a, err := New(&common.AuthConfig{}); a != nil || err != nil {
+		t.Errorf("empty config should disable auth, got %v, %v", a, err)
+	}
+}
+
+// TestJWTVerify 测试 HS256 / EdDSA 签名校验与 claim 到 scope 的映射
+func TestJWTVerify(t *testing.T) {
+	secret := []byte("test-secret")
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := fmt.Sprintf(`{"keys":[
+		{"kty":"oct","kid":"hs","alg":"HS256","k":"%s"},
+		{"kty":"OKP","kid":"ed","crv":"Ed25519","x":"%s"}
+	]}`, b64(secret), b64(pub))
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(jwksFile, []byte(jwks), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := NewJWTVerifier(&common.JWTConfig{
+		JWKSFile: jwksFile,
+		Issuer:   "https://issuer.example",
+		Rules: []common.JWTClaimRule{
+			{Claim: "tenant", Scopes: []string{"rdb:{value}:*", "kv:{value}:get"}},
+			{Claim: "realm.roles", Value: "admin", Scopes: []string{"admin:reload"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := map[string]any{
+		"sub":    "alice",
+		"iss":    "https://issuer.example",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"tenant": "acme",
+		"realm":  map[string]any{"roles": []string{"user", "admin"}},
+	}
+
+	hsToken := signJWT(t, "HS256", "hs", claims, func(signed []byte) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		return mac.Sum(nil)
+	})
+	edToken := signJWT(t, "EdDSA", "ed", claims, func(signed []byte) []byte {
+		return ed25519.Sign(priv, signed)
+	})
+
+	for name, token := range map[string]string{"HS256": hsToken, "EdDSA": edToken} {
+		p, err := v.Verify(token)
+		if err != nil {
+			t.Fatalf("%s: Verify failed: %v", name, err)
+		}
+		if p.Name != "alice" || p.Claims["tenant"] != "acme" {
+			t.Errorf("%s: unexpected principal %+v", name, p)
+		}
+		if !p.Allows("rdb", "acme", "exec") || !p.Allows("kv", "acme", "get") || !p.Allows("admin", "", "reload") {
+			t.Errorf("%s: missing mapped scopes: %v", name, p.Scopes)
+		}
+		if p.Allows("rdb", "other", "query") || p.Allows("kv", "acme", "set") {
+			t.Errorf("%s: unexpected scopes: %v", name, p.Scopes)
+		}
+	}
+
+	// 篡改 payload 后签名应失效
+	parts := strings.Split(hsToken, ".")
+	forged := parts[0] + "." + b64([]byte(`{"sub":"mallory","tenant":"*"}`)) + "." + parts[2]
+	if _, err := v.Verify(forged); err == nil {
+		t.Error("forged token should be rejected")
+	}
+
+	// alg 与 key 不一致时应拒绝
+	confused := signJWT(t, "HS256", "ed", claims, func(signed []byte) []byte {
+		mac := hmac.New(sha256.New, pub)
+		mac.Write(signed)
+		return mac.Sum(nil)
+	})
+	if _, err := v.Verify(confused); err == nil {
+		t.Error("alg confusion token should be rejected")
+	}
+
+	claims["exp"] = time.Now().Add(-time.Hour).Unix()
+	expired := signJWT(t, "EdDSA", "ed", claims, func(signed []byte) []byte {
+		return ed25519.Sign(priv, signed)
+	})
+	if _, err := v.Verify(expired); err == nil {
+		t.Error("expired token should be rejected")
+	}
+
+	// 没有 sub 的令牌无法区分主体，应拒绝
+	claims["exp"] = time.Now().Add(time.Hour).Unix()
+	delete(claims, "sub")
+	anonymous := signJWT(t, "EdDSA", "ed", claims, func(signed []byte) []byte {
+		return ed25519.Sign(priv, signed)
+	})
+	if _, err := v.Verify(anonymous); err == nil {
+		t.Error("token without sub should be rejected")
+	}
+
+	// claim 值不能扩大 scope
+	claims["sub"] = "mallory"
+	for _, tenant := range []string{"*", "acme:*:*", ""} {
+		claims["tenant"] = tenant
+		token := signJWT(t, "EdDSA", "ed", claims, func(signed []byte) []byte {
+			return ed25519.Sign(priv, signed)
+		})
+		p, err := v.Verify(token)
+		if err != nil {
+			t.Fatalf("tenant %q: Verify failed: %v", tenant, err)
+		}
+		if p.Allows("rdb", "other", "query") || p.Allows("rdb", "acme", "query") {
+			t.Errorf("tenant %q: unexpected scopes: %v", tenant, p.Scopes)
+		}
+	}
+
+	// 默认要求 exp
+	delete(claims, "exp")
+	eternal := signJWT(t, "EdDSA", "ed", claims, func(signed []byte) []byte {
+		return ed25519.Sign(priv, signed)
+	})
+	if _, err := v.Verify(eternal); err == nil {
+		t.Error("token without exp should be rejected")
+	}
+	v.allowNoExpiry = true
+	if _, err := v.Verify(eternal); err != nil {
+		t.Errorf("allowNoExpiry: %v", err)
+	}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func signJWT(t *testing.T, alg, kid string, claims map[string]any, sign func([]byte) []byte) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := b64(header) + "." + b64(payload)
+	return signed + "." + b64(sign([]byte(signed)))
+}
@@ -0,0 +1,352 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+// jwk 为 JWKS 中单个 key 的 JSON 表示，只解析需要的字段
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	K   string `json:"k"` // oct
+	N   string `json:"n"` // RSA
+	E   string `json:"e"` // RSA
+	X   string `json:"x"` // OKP
+}
+
+type verifyKey struct {
+	kid string
+	alg string
+	key any // []byte | *rsa.PublicKey | ed25519.PublicKey
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+type claimRule struct {
+	path   []string
+	value  string
+	scopes []string
+}
+
+// JWTVerifier 校验 JWT 并把 claims 映射为 scope
+type JWTVerifier struct {
+	keys     []verifyKey
+	issuer   string
+	audience string
+	leeway   time.Duration
+	rules    []claimRule
+	now      func() time.Time
+
+	allowNoExpiry bool
+}
+
+// NewJWTVerifier builds a verifier from config, loading keys from the JWKS file
+func NewJWTVerifier(conf *common.JWTConfig) (*JWTVerifier, error) {
+	data, err := os.ReadFile(conf.JWKSFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &JWTVerifier{
+		keys:     keys,
+		issuer:   conf.Issuer,
+		audience: conf.Audience,
+		leeway:   time.Duration(conf.Leeway) * time.Second,
+		now:      time.Now,
+
+		allowNoExpiry: conf.AllowNoExpiry,
+	}
+
+	for i, r := range conf.Rules {
+		if r.Claim == "" {
+			return nil, fmt.Errorf("jwt rule %d: claim is required", i)
+		}
+		// 校验 scope 模板，占位符替换为合法 ID 后应能解析
+		for _, s := range r.Scopes {
+			if _, err := ParseScope(strings.ReplaceAll(s, "{value}", "x")); err != nil {
+				return nil, fmt.Errorf("jwt rule %d: %w", i, err)
+			}
+		}
+		value := r.Value
+		if value == "" {
+			value = wildcard
+		}
+		v.rules = append(v.rules, claimRule{
+			path:   strings.Split(r.Claim, "."),
+			value:  value,
+			scopes: r.Scopes,
+		})
+	}
+	return v, nil
+}
+
+func parseJWKS(data []byte) ([]verifyKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	keys := make([]verifyKey, 0, len(set.Keys))
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		vk, err := k.verifyKey()
+		if err != nil {
+			return nil, fmt.Errorf("JWKS key %d (%s): %w", i, k.Kid, err)
+		}
+		keys = append(keys, vk)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS contains no signing keys")
+	}
+	return keys, nil
+}
+
+func (k jwk) verifyKey() (verifyKey, error) {
+	vk := verifyKey{kid: k.Kid, alg: k.Alg}
+	switch k.Kty {
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return vk, fmt.Errorf("invalid oct key")
+		}
+		vk.key = secret
+		if vk.alg == "" {
+			vk.alg = "HS256"
+		}
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return vk, fmt.Errorf("invalid RSA modulus")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return vk, fmt.Errorf("invalid RSA exponent")
+		}
+		vk.key = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if vk.alg == "" {
+			vk.alg = "RS256"
+		}
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return vk, fmt.Errorf("unsupported OKP curve: %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return vk, fmt.Errorf("invalid Ed25519 public key")
+		}
+		vk.key = ed25519.PublicKey(x)
+		if vk.alg == "" {
+			vk.alg = "EdDSA"
+		}
+	default:
+		return vk, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+
+	switch vk.alg {
+	case "HS256", "RS256", "EdDSA":
+	default:
+		return vk, fmt.Errorf("unsupported algorithm: %s", vk.alg)
+	}
+	return vk, nil
+}
+
+// Verify checks the token signature and registered claims, then maps claims to scopes
+func (v *JWTVerifier) Verify(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if !v.verifySignature(header, []byte(parts[0]+"."+parts[1]), sig) {
+		return nil, ErrInvalidToken
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err := v.validateClaims(claims); err != nil {
+		return nil, err
+	}
+
+	// sub 用作限流与日志的主体，缺少时所有令牌会共享同一个身份
+	name, _ := claims["sub"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("%w: missing sub claim", ErrInvalidToken)
+	}
+	return &Principal{
+		Name:   name,
+		Scopes: v.mapScopes(claims),
+		Claims: claims,
+	}, nil
+}
+
+func (v *JWTVerifier) verifySignature(header jwtHeader, signed []byte, sig []byte) bool {
+	for _, k := range v.keys {
+		// alg 必须与 key 声明一致，防止 alg 混淆攻击
+		if k.alg != header.Alg {
+			continue
+		}
+		if header.Kid != "" && k.kid != "" && k.kid != header.Kid {
+			continue
+		}
+		switch key := k.key.(type) {
+		case []byte:
+			mac := hmac.New(sha256.New, key)
+			mac.Write(signed)
+			if hmac.Equal(mac.Sum(nil), sig) {
+				return true
+			}
+		case *rsa.PublicKey:
+			digest := sha256.Sum256(signed)
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil {
+				return true
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(key, signed, sig) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (v *JWTVerifier) validateClaims(claims map[string]any) error {
+	now := v.now()
+	exp, ok := numericClaim(claims, "exp")
+	if !ok && !v.allowNoExpiry {
+		return fmt.Errorf("%w: missing exp claim", ErrInvalidToken)
+	}
+	if ok && now.After(exp.Add(v.leeway)) {
+		return fmt.Errorf("%w: token expired", ErrInvalidToken)
+	}
+	if nbf, ok := numericClaim(claims, "nbf"); ok && now.Add(v.leeway).Before(nbf) {
+		return fmt.Errorf("%w: token not yet valid", ErrInvalidToken)
+	}
+	if v.issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.issuer {
+			return fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+		}
+	}
+	if v.audience != "" && !slices.Contains(claimStrings(claims["aud"]), v.audience) {
+		return fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+	return nil
+}
+
+// mapScopes 依次应用规则，{value} 替换为命中的 claim 值
+func (v *JWTVerifier) mapScopes(claims map[string]any) []Scope {
+	var scopes []Scope
+	for _, r := range v.rules {
+		for _, val := range claimStrings(lookupClaim(claims, r.path)) {
+			if r.value != wildcard && r.value != val {
+				continue
+			}
+			// 代入 {value} 之前检查，防止 "*" 或 "a:b:*" 这样的值扩大 scope
+			if !scopeValue(val) {
+				continue
+			}
+			for _, tmpl := range r.scopes {
+				s, err := ParseScope(strings.ReplaceAll(tmpl, "{value}", val))
+				if err != nil {
+					continue
+				}
+				scopes = append(scopes, s)
+			}
+		}
+	}
+	return scopes
+}
+
+// scopeValue claim 值只能作为 scope 中的一段，不能为空或带有通配符、分隔符
+func scopeValue(val string) bool {
+	return val != "" && !strings.ContainsAny(val, wildcard+":")
+}
+
+func decodeSegment(seg string, out any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+func numericClaim(claims map[string]any, name string) (time.Time, bool) {
+	n, ok := claims[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(n), 0), true
+}
+
+// lookupClaim 支持 "realm_access.roles" 形式的嵌套路径
+func lookupClaim(claims map[string]any, path []string) any {
+	var cur any = claims
+	for _, p := range path {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[p]
+	}
+	return cur
+}
+
+// claimStrings 把 string / []string / 数字 统一为字符串列表
+func claimStrings(v any) []string {
+	switch val := v.(type) {
+	case string:
+		return []string{val}
+	case float64, bool:
+		return []string{fmt.Sprint(val)}
+	case []any:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			out = append(out, claimStrings(item)...)
+		}
+		return out
+	default:
+		return nil
+	}
+}
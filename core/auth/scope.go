@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Scope 描述一个授权范围，格式为 kind:id:op 或 admin:op
+//   - rdb:app:query   允许对 RDB app 执行 query
+//   - kv:cache:*      允许对 KV cache 执行任意操作
+//   - s3:*:get        允许读取任意 S3 实例
+//   - admin:reload    允许调用 /reload
+//   - admin:instances 允许调用 /admin/{rdb,kv,s3}/:id，并在 /health/ready 中查看每个实例
+//   - admin:monitor   允许调用 /monitor 的只读方法
+//   - admin:rollback  另外允许通过 /monitor 调用 config.rollback
+//   - *               允许一切
+type Scope struct {
+	Kind string
+	ID   string
+	Op   string
+}
+
+const wildcard = "*"
+
+// ParseScope parses a scope string into a Scope
+func ParseScope(raw string) (Scope, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return Scope{}, fmt.Errorf("empty scope")
+	}
+	if raw == wildcard {
+		return Scope{Kind: wildcard, ID: wildcard, Op: wildcard}, nil
+	}
+
+	parts := strings.Split(raw, ":")
+	for _, p := range parts {
+		if p == "" {
+			return Scope{}, fmt.Errorf("invalid scope %q: empty segment", raw)
+		}
+	}
+
+	switch parts[0] {
+	case "admin":
+		if len(parts) != 2 {
+			return Scope{}, fmt.Errorf("invalid scope %q: expected admin:<op>", raw)
+		}
+		return Scope{Kind: "admin", Op: parts[1]}, nil
+	case "rdb", "kv", "s3", wildcard:
+		if len(parts) != 3 {
+			return Scope{}, fmt.Errorf("invalid scope %q: expected %s:<id>:<op>", raw, parts[0])
+		}
+		return Scope{Kind: parts[0], ID: parts[1], Op: parts[2]}, nil
+	default:
+		return Scope{}, fmt.Errorf("invalid scope %q: unknown kind %s", raw, parts[0])
+	}
+}
+
+// ParseScopes parses a list of scope strings
+func ParseScopes(raw []string) ([]Scope, error) {
+	scopes := make([]Scope, 0, len(raw))
+	for _, s := range raw {
+		scope, err := ParseScope(s)
+		if err != nil {
+			return nil, err
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, nil
+}
+
+// Match reports whether the scope grants access to the requested target
+func (s Scope) Match(kind, id, op string) bool {
+	return matchSegment(s.Kind, kind) && matchSegment(s.ID, id) && matchSegment(s.Op, op)
+}
+
+func (s Scope) String() string {
+	if s.Kind == wildcard && s.ID == wildcard && s.Op == wildcard {
+		return wildcard
+	}
+	if s.Kind == "admin" {
+		return s.Kind + ":" + s.Op
+	}
+	return s.Kind + ":" + s.ID + ":" + s.Op
+}
+
+func matchSegment(pattern, value string) bool {
+	return pattern == wildcard || pattern == value
+}
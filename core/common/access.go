@@ -0,0 +1,30 @@
+package combinator
+
+import "fmt"
+
+// AccessMode 实例级访问模式，由各服务在网关内强制执行
+type AccessMode string
+
+const (
+	ModeReadWrite  AccessMode = "read-write"
+	ModeReadOnly   AccessMode = "read-only"
+	ModeAppendOnly AccessMode = "append-only" // 只允许新增（RDB 的 INSERT），不允许修改与删除
+)
+
+// ResolveAccessMode 合并 mode 与 readOnly 简写，都为空时为 read-write
+func ResolveAccessMode(mode AccessMode, readOnly bool) (AccessMode, error) {
+	switch mode {
+	case "":
+		mode = ModeReadWrite
+	case ModeReadWrite, ModeReadOnly, ModeAppendOnly:
+	default:
+		return "", fmt.Errorf("metadata: unknown mode %q, expected read-write, read-only or append-only", mode)
+	}
+	if readOnly {
+		if mode != ModeReadWrite && mode != ModeReadOnly {
+			return "", fmt.Errorf("metadata: readOnly conflicts with mode %q", mode)
+		}
+		mode = ModeReadOnly
+	}
+	return mode, nil
+}
@@ -0,0 +1,336 @@
+package combinator
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BreakerState 熔断器状态
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerHalfOpen
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	}
+	return "unknown"
+}
+
+// BreakerSettings 解析后的熔断参数，见 CircuitBreakerConfig
+type BreakerSettings struct {
+	Disabled         bool
+	MinRequests      int
+	FailureRatio     float64
+	SlowCall         time.Duration // 0 表示不按耗时判定
+	Window           time.Duration
+	OpenTimeout      time.Duration
+	HalfOpenRequests int
+}
+
+// DefaultBreakerSettings 未配置 circuitBreaker 时使用
+var DefaultBreakerSettings = BreakerSettings{
+	MinRequests:      10,
+	FailureRatio:     0.5,
+	SlowCall:         5 * time.Second,
+	Window:           time.Minute,
+	OpenTimeout:      30 * time.Second,
+	HalfOpenRequests: 1,
+}
+
+// NewBreakerSettings 校验配置并补全默认值，conf 为 nil 时返回默认值
+func NewBreakerSettings(conf *CircuitBreakerConfig) (*BreakerSettings, error) {
+	s := DefaultBreakerSettings
+	if conf == nil {
+		return &s, nil
+	}
+	if conf.MinRequests < 0 || conf.SlowCallMs < 0 || conf.Window < 0 || conf.OpenTimeout < 0 || conf.HalfOpenRequests < 0 {
+		return nil, fmt.Errorf("circuitBreaker: values must not be negative")
+	}
+	if conf.FailureRatio < 0 || conf.FailureRatio > 1 {
+		return nil, fmt.Errorf("circuitBreaker: failureRatio must be between 0 and 1, got %v", conf.FailureRatio)
+	}
+	s.Disabled = conf.Disabled
+	if conf.MinRequests > 0 {
+		s.MinRequests = conf.MinRequests
+	}
+	if conf.FailureRatio > 0 {
+		s.FailureRatio = conf.FailureRatio
+	}
+	if conf.SlowCallMs > 0 {
+		s.SlowCall = time.Duration(conf.SlowCallMs) * time.Millisecond
+	}
+	if conf.Window > 0 {
+		s.Window = time.Duration(conf.Window) * time.Second
+	}
+	if conf.OpenTimeout > 0 {
+		s.OpenTimeout = time.Duration(conf.OpenTimeout) * time.Second
+	}
+	if conf.HalfOpenRequests > 0 {
+		s.HalfOpenRequests = conf.HalfOpenRequests
+	}
+	return &s, nil
+}
+
+// BreakerPolicy 同一网关的所有熔断器共享的参数，重载时整体替换
+type BreakerPolicy struct {
+	atomic.Pointer[BreakerSettings]
+}
+
+func NewBreakerPolicy() *BreakerPolicy {
+	p := &BreakerPolicy{}
+	s := DefaultBreakerSettings
+	p.Store(&s)
+	return p
+}
+
+// BreakerOpenError 熔断器打开（或半开且探测名额已满）时直接返回，不调用后端
+type BreakerOpenError struct {
+	Kind       string
+	ID         string
+	RetryAfter time.Duration
+}
+
+func (e *BreakerOpenError) Error() string {
+	return fmt.Sprintf("%s %s circuit breaker is open, retry in %s",
+		strings.ToUpper(e.Kind), e.ID, e.RetryAfter.Round(time.Second))
+}
+
+// IsTransientError 判断错误是否说明后端本身有问题（连接失败、超时、连接被断开），
+// 语法错误、key 不存在等由请求引起的错误不计入熔断
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// BreakerStatus 熔断器当前状态，供 /monitor 使用
+type BreakerStatus struct {
+	State    string    `json:"state"`
+	Requests int       `json:"requests"` // 当前统计窗口（或半开探测）内的调用数
+	Failures int       `json:"failures"`
+	OpenedAt time.Time `json:"openedAt,omitzero"`
+	Trips    uint64    `json:"trips"`
+	Rejected uint64    `json:"rejected"`
+}
+
+// Breaker 单个实例的熔断器
+// closed：按固定窗口统计失败（含慢调用）比例，达到阈值后打开；
+// open：直接拒绝，OpenTimeout 后进入 half-open；
+// half-open：放行 HalfOpenRequests 个探测请求，全部成功则关闭，任一失败重新打开
+type Breaker struct {
+	kind      string
+	id        string
+	policy    *BreakerPolicy
+	isFailure func(error) bool
+
+	mu          sync.Mutex
+	state       BreakerState
+	gen         uint64 // 状态或窗口变化时递增，旧请求的结果不再计入
+	windowStart time.Time
+	requests    int
+	failures    int
+	inFlight    int // half-open 中尚未返回的探测请求
+	openedAt    time.Time
+	trips       uint64
+	rejected    uint64
+}
+
+// NewBreaker isFailure 为 nil 时使用 IsTransientError
+func NewBreaker(kind, id string, policy *BreakerPolicy, isFailure func(error) bool) *Breaker {
+	if isFailure == nil {
+		isFailure = IsTransientError
+	}
+	return &Breaker{
+		kind:        kind,
+		id:          id,
+		policy:      policy,
+		isFailure:   isFailure,
+		windowStart: time.Now(),
+	}
+}
+
+// Do 经过熔断器调用 fn
+func (b *Breaker) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	s := b.policy.Load()
+	if s.Disabled {
+		return fn(ctx)
+	}
+	gen, err := b.before(s)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = fn(ctx)
+	b.after(ctx, s, gen, time.Since(start), err)
+	return err
+}
+
+// Call 是带返回值的 Do
+func Call[T any](b *Breaker, ctx context.Context, fn func(ctx context.Context) (T, error)) (T, error) {
+	var out T
+	err := b.Do(ctx, func(ctx context.Context) error {
+		var err error
+		out, err = fn(ctx)
+		return err
+	})
+	return out, err
+}
+
+func (b *Breaker) before(s *BreakerSettings) (uint64, error) {
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(s, now)
+
+	switch b.state {
+	case BreakerOpen:
+		b.rejected++
+		return 0, &BreakerOpenError{Kind: b.kind, ID: b.id, RetryAfter: b.openedAt.Add(s.OpenTimeout).Sub(now)}
+	case BreakerHalfOpen:
+		if b.inFlight+b.requests >= s.HalfOpenRequests {
+			b.rejected++
+			return 0, &BreakerOpenError{Kind: b.kind, ID: b.id}
+		}
+		b.inFlight++
+	}
+	return b.gen, nil
+}
+
+func (b *Breaker) after(ctx context.Context, s *BreakerSettings, gen uint64, d time.Duration, err error) {
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(s, now)
+	if gen != b.gen {
+		return
+	}
+	if b.state == BreakerHalfOpen {
+		b.inFlight--
+	}
+	// 客户端断开导致的取消不说明后端状态
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return
+	}
+	failed := b.isFailure(err) || (s.SlowCall > 0 && d >= s.SlowCall)
+
+	switch b.state {
+	case BreakerClosed:
+		b.requests++
+		if failed {
+			b.failures++
+			if b.requests >= s.MinRequests && float64(b.failures)/float64(b.requests) >= s.FailureRatio {
+				b.setState(BreakerOpen, now)
+			}
+		}
+	case BreakerHalfOpen:
+		if failed {
+			b.setState(BreakerOpen, now)
+			return
+		}
+		b.requests++
+		if b.requests >= s.HalfOpenRequests {
+			b.setState(BreakerClosed, now)
+		}
+	}
+}
+
+// advance 处理随时间发生的变化：closed 窗口滚动、open 超时进入 half-open
+func (b *Breaker) advance(s *BreakerSettings, now time.Time) {
+	switch b.state {
+	case BreakerClosed:
+		if now.Sub(b.windowStart) >= s.Window {
+			b.gen++
+			b.windowStart = now
+			b.requests, b.failures = 0, 0
+		}
+	case BreakerOpen:
+		if now.Sub(b.openedAt) >= s.OpenTimeout {
+			b.setState(BreakerHalfOpen, now)
+		}
+	}
+}
+
+func (b *Breaker) setState(state BreakerState, now time.Time) {
+	prev := b.state
+	b.state = state
+	b.gen++
+	b.windowStart = now
+	b.requests, b.failures, b.inFlight = 0, 0, 0
+
+	name := strings.ToUpper(b.kind)
+	switch state {
+	case BreakerOpen:
+		b.openedAt = now
+		b.trips++
+		InstanceLog(b.kind, b.id).Warnf("Circuit breaker for %s %s opened (was %s)", name, b.id, prev)
+	case BreakerHalfOpen:
+		InstanceLog(b.kind, b.id).Infof("Circuit breaker for %s %s is half-open, probing", name, b.id)
+	case BreakerClosed:
+		b.openedAt = time.Time{}
+		InstanceLog(b.kind, b.id).Infof("Circuit breaker for %s %s closed", name, b.id)
+	}
+}
+
+// State 返回当前状态
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(b.policy.Load(), time.Now())
+	return b.state
+}
+
+// Status 返回当前状态与计数
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(b.policy.Load(), time.Now())
+	return BreakerStatus{
+		State:    b.state.String(),
+		Requests: b.requests,
+		Failures: b.failures,
+		OpenedAt: b.openedAt,
+		Trips:    b.trips,
+		Rejected: b.rejected,
+	}
+}
+
+// Breakered 由带熔断器的实例包装实现
+type Breakered interface {
+	Breaker() *Breaker
+}
+
+// BreakerOf 返回实例的熔断器，实例未包装时返回 nil
+func BreakerOf(s Service) *Breaker {
+	if b, ok := s.(Breakered); ok {
+		return b.Breaker()
+	}
+	return nil
+}
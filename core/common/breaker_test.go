@@ -0,0 +1,82 @@
+package combinator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreakerTransitions(t *testing.T) {
+	policy := NewBreakerPolicy()
+	policy.Store(&BreakerSettings{
+		MinRequests:      4,
+		FailureRatio:     0.5,
+		SlowCall:         50 * time.Millisecond,
+		Window:           time.Minute,
+		OpenTimeout:      20 * time.Millisecond,
+		HalfOpenRequests: 1,
+	})
+	b := NewBreaker("kv", "a", policy, nil)
+	ctx := context.Background()
+	down := &connRefused{}
+	ok := func(ctx context.Context) error { return nil }
+	fail := func(ctx context.Context) error { return down }
+
+	// 非后端错误不计入失败
+	for i := 0; i < 4; i++ {
+		_ = b.Do(ctx, func(ctx context.Context) error { return errors.New("syntax error") })
+	}
+	if b.State() != BreakerClosed {
+		t.Fatal("request errors opened the breaker")
+	}
+
+	// 窗口内 2/4 失败（含一次慢调用）达到阈值
+	b = NewBreaker("kv", "a", policy, nil)
+	_ = b.Do(ctx, ok)
+	_ = b.Do(ctx, fail)
+	_ = b.Do(ctx, ok)
+	_ = b.Do(ctx, func(ctx context.Context) error { time.Sleep(60 * time.Millisecond); return nil })
+	if b.State() != BreakerOpen {
+		t.Fatalf("want open, got %s", b.State())
+	}
+	called := false
+	err := b.Do(ctx, func(ctx context.Context) error { called = true; return nil })
+	var open *BreakerOpenError
+	if called || !errors.As(err, &open) || ErrorStatus(err) != 503 {
+		t.Fatalf("open breaker did not fail fast: called=%v err=%v", called, err)
+	}
+
+	// half-open 探测失败重新打开，成功则关闭
+	time.Sleep(25 * time.Millisecond)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("want half-open, got %s", b.State())
+	}
+	_ = b.Do(ctx, fail)
+	if b.State() != BreakerOpen {
+		t.Fatalf("failed probe: want open, got %s", b.State())
+	}
+	time.Sleep(25 * time.Millisecond)
+	if err := b.Do(ctx, ok); err != nil {
+		t.Fatal(err)
+	}
+	if st := b.Status(); st.State != "closed" || st.Trips != 2 || st.Rejected != 1 {
+		t.Fatalf("unexpected status %+v", st)
+	}
+
+	// 关闭后不经过熔断器
+	policy.Store(&BreakerSettings{Disabled: true})
+	for i := 0; i < 10; i++ {
+		_ = b.Do(ctx, fail)
+	}
+	if b.State() != BreakerClosed {
+		t.Fatal("disabled breaker changed state")
+	}
+}
+
+// connRefused 模拟连接失败
+type connRefused struct{}
+
+func (*connRefused) Error() string   { return "connection refused" }
+func (*connRefused) Timeout() bool   { return false }
+func (*connRefused) Temporary() bool { return false }
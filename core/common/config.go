@@ -1,31 +1,304 @@
 package combinator
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Config 网关配置，init 与 dev 使用同一格式；实例条目可以只写 ID，见 RDBConfig
 type Config struct {
-	Rdb []RDBConfig `json:"rdb"`
-	Kv  []KVConfig  `json:"kv"`
-	S3  []S3Config  `json:"s3"`
+	Schema    string           `json:"$schema,omitempty"` // 编辑器补全用，例如 "./config.schema.json"
+	Rdb       []RDBConfig      `json:"rdb"`
+	Kv        []KVConfig       `json:"kv"`
+	S3        []S3Config       `json:"s3"`
+	Auth      *AuthConfig      `json:"auth,omitempty"`
+	RateLimit *RateLimitConfig `json:"rateLimit,omitempty"`
+
+	CircuitBreaker *CircuitBreakerConfig `json:"circuitBreaker,omitempty"`
+	Timeouts       *TimeoutConfig        `json:"timeouts,omitempty"`
+	CORS           *CORSConfig           `json:"cors,omitempty"`
+}
+
+// Clone 返回配置的深拷贝
+func (c *Config) Clone() (*Config, error) {
+	buf, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var out Config
+	if err := json.Unmarshal(buf, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WriteConfigFile 按扩展名选择格式写入配置，见 WriteConfigFileAs
+func WriteConfigFile(path string, conf *Config) error {
+	return WriteConfigFileAs(path, conf, ConfigFormatFromPath(path))
+}
+
+// WriteConfigFileAs 先写临时文件再重命名，避免 watch 读到写了一半的文件
+func WriteConfigFileAs(path string, conf *Config, format ConfigFormat) error {
+	buf, err := EncodeConfig(conf, format)
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf, mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
 }
 
+// RDBConfig 实例条目，可以写成完整对象，也可以只写 ID 字符串（例如 "rdb": ["main"]）；
+// URL 为空时使用 DefaultURL，dev 模式会把所有实例替换为本地后端
 type RDBConfig struct {
 	ID       string `json:"id"`
-	URL      string `json:"url"`
+	URL      string `json:"url,omitempty"`
 	Metadata any    `json:"metadata,omitempty"`
 }
 
 type KVConfig struct {
 	ID       string `json:"id"`
-	URL      string `json:"url"`
+	URL      string `json:"url,omitempty"`
 	Metadata any    `json:"metadata,omitempty"`
 }
 
 type S3Config struct {
 	ID       string `json:"id"`
-	URL      string `json:"url"`
+	URL      string `json:"url,omitempty"`
 	Metadata any    `json:"metadata,omitempty"`
 }
 
-type DevConfig struct {
-	Rdb []string `json:"rdb"`
-	Kv  []string `json:"kv"`
-	S3  []string `json:"s3"`
+// DefaultURL 只写 ID 的实例使用的后端：当前目录下的 sqlite 文件、内存 KV、当前目录下的本地存储
+func DefaultURL(kind, id string) string {
+	switch kind {
+	case "rdb":
+		return "sqlite://" + id + ".sqlite"
+	case "kv":
+		return "memory://"
+	case "s3":
+		return "local://s3/" + id
+	}
+	return ""
+}
+
+// shorthandID 条目为 JSON 字符串时返回其内容
+func shorthandID(data []byte) (string, bool) {
+	var id string
+	if len(data) == 0 || data[0] != '"' || json.Unmarshal(data, &id) != nil {
+		return "", false
+	}
+	return id, true
+}
+
+func (c *RDBConfig) UnmarshalJSON(data []byte) error {
+	if id, ok := shorthandID(data); ok {
+		*c = RDBConfig{ID: id}
+		return nil
+	}
+	type plain RDBConfig
+	return json.Unmarshal(data, (*plain)(c))
+}
+
+func (c *KVConfig) UnmarshalJSON(data []byte) error {
+	if id, ok := shorthandID(data); ok {
+		*c = KVConfig{ID: id}
+		return nil
+	}
+	type plain KVConfig
+	return json.Unmarshal(data, (*plain)(c))
+}
+
+func (c *S3Config) UnmarshalJSON(data []byte) error {
+	if id, ok := shorthandID(data); ok {
+		*c = S3Config{ID: id}
+		return nil
+	}
+	type plain S3Config
+	return json.Unmarshal(data, (*plain)(c))
+}
+
+// MarshalJSON 只有 ID 的条目写回为字符串，保持配置文件的简写形式
+func (c RDBConfig) MarshalJSON() ([]byte, error) {
+	if c.URL == "" && c.Metadata == nil {
+		return json.Marshal(c.ID)
+	}
+	type plain RDBConfig
+	return json.Marshal(plain(c))
+}
+
+func (c KVConfig) MarshalJSON() ([]byte, error) {
+	if c.URL == "" && c.Metadata == nil {
+		return json.Marshal(c.ID)
+	}
+	type plain KVConfig
+	return json.Marshal(plain(c))
+}
+
+func (c S3Config) MarshalJSON() ([]byte, error) {
+	if c.URL == "" && c.Metadata == nil {
+		return json.Marshal(c.ID)
+	}
+	type plain S3Config
+	return json.Marshal(plain(c))
+}
+
+// applyDefaultURLs 为只写 ID 的实例填上 DefaultURL
+func (c *Config) applyDefaultURLs() {
+	for i := range c.Rdb {
+		if c.Rdb[i].URL == "" {
+			c.Rdb[i].URL = DefaultURL("rdb", c.Rdb[i].ID)
+		}
+	}
+	for i := range c.Kv {
+		if c.Kv[i].URL == "" {
+			c.Kv[i].URL = DefaultURL("kv", c.Kv[i].ID)
+		}
+	}
+	for i := range c.S3 {
+		if c.S3[i].URL == "" {
+			c.S3[i].URL = DefaultURL("s3", c.S3[i].ID)
+		}
+	}
+}
+
+func (c RDBConfig) InstanceID() string { return c.ID }
+func (c KVConfig) InstanceID() string  { return c.ID }
+func (c S3Config) InstanceID() string  { return c.ID }
+
+// InstanceURL 配置中写的 URL，只写 ID 的实例为空
+func (c RDBConfig) InstanceURL() string { return c.URL }
+func (c KVConfig) InstanceURL() string  { return c.URL }
+func (c S3Config) InstanceURL() string  { return c.URL }
+
+// Same 比较 URL 与 Metadata，任一变化都需要重建实例
+func (c RDBConfig) Same(o RDBConfig) bool {
+	return c.URL == o.URL && sameMetadata(c.Metadata, o.Metadata)
+}
+func (c KVConfig) Same(o KVConfig) bool {
+	return c.URL == o.URL && sameMetadata(c.Metadata, o.Metadata)
+}
+func (c S3Config) Same(o S3Config) bool {
+	return c.URL == o.URL && sameMetadata(c.Metadata, o.Metadata)
+}
+
+// sameMetadata 按 JSON 编码比较，map 的 key 顺序不影响结果
+func sameMetadata(a, b any) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
+
+// DecodeMetadata 把 Metadata 解码为各服务的选项结构体，未知字段视为错误
+func DecodeMetadata(metadata any, out any) error {
+	if metadata == nil {
+		return nil
+	}
+	buf, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("metadata: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(out); err != nil {
+		return fmt.Errorf("metadata: %w", err)
+	}
+	return nil
+}
+
+// AuthConfig 网关认证配置，未配置任何 key 和 jwt 时不启用认证
+type AuthConfig struct {
+	Keys []APIKeyConfig `json:"keys"`
+	JWT  *JWTConfig     `json:"jwt,omitempty"`
+}
+
+// APIKeyConfig 一个 bearer API key 及其授权范围
+// Key 为明文（仅建议本地使用），Hash 为 "sha256:<hex>"，二者取其一
+type APIKeyConfig struct {
+	Name   string   `json:"name"`
+	Key    string   `json:"key,omitempty"`
+	Hash   string   `json:"hash,omitempty"`
+	Scopes []string `json:"scopes"`
+}
+
+// JWTConfig 使用 JWKS 文件校验 JWT (HS256 / RS256 / EdDSA)
+type JWTConfig struct {
+	JWKSFile string         `json:"jwksFile"`
+	Issuer   string         `json:"issuer,omitempty"`
+	Audience string         `json:"audience,omitempty"`
+	Leeway   int            `json:"leeway,omitempty"` // 时钟偏差容忍（秒）
+	Rules    []JWTClaimRule `json:"rules"`
+	// AllowNoExpiry 接受没有 exp 的令牌，默认拒绝，避免泄露的令牌永久有效
+	AllowNoExpiry bool `json:"allowNoExpiry,omitempty"`
+}
+
+// JWTClaimRule 把 claim 值映射为 scope
+// Claim 支持 "realm_access.roles" 形式的嵌套路径，Value 为空或 "*" 匹配任意值，
+// Scopes 中的 {value} 会替换为命中的 claim 值，例如 tenant -> "rdb:{value}:*"
+type JWTClaimRule struct {
+	Claim  string   `json:"claim"`
+	Value  string   `json:"value,omitempty"`
+	Scopes []string `json:"scopes"`
+}
+
+// RateLimitConfig 令牌桶限流配置
+// Store 为某个 KV 实例的 ID，设置后限流状态在多个网关副本之间共享
+type RateLimitConfig struct {
+	Store string          `json:"store,omitempty"`
+	Rules []RateLimitRule `json:"rules"`
+}
+
+// RateLimitRule 一条限流规则
+// Key 为 "apikey"、"ip" 或 "instance"，Target 使用与 auth scope 相同的格式，
+// 例如 {"key": "instance", "target": "rdb:app:exec", "rate": 100}
+type RateLimitRule struct {
+	Name   string  `json:"name,omitempty"`
+	Key    string  `json:"key"`
+	Target string  `json:"target,omitempty"`
+	Rate   float64 `json:"rate"` // 每秒令牌数
+	Burst  int     `json:"burst,omitempty"`
+}
+
+// CircuitBreakerConfig 每个实例一个熔断器，未配置时使用默认值，字段为 0 时取默认值
+// 连接失败、超时以及耗时超过 SlowCallMs 的调用计为失败
+type CircuitBreakerConfig struct {
+	Disabled         bool    `json:"disabled,omitempty"`
+	MinRequests      int     `json:"minRequests,omitempty"`      // 窗口内至少多少次调用才判断，默认 10
+	FailureRatio     float64 `json:"failureRatio,omitempty"`     // 失败比例阈值，默认 0.5
+	SlowCallMs       int     `json:"slowCallMs,omitempty"`       // 慢调用阈值（毫秒），默认 5000
+	Window           int     `json:"window,omitempty"`           // 统计窗口（秒），默认 60
+	OpenTimeout      int     `json:"openTimeout,omitempty"`      // 打开后多久进入半开（秒），默认 30
+	HalfOpenRequests int     `json:"halfOpenRequests,omitempty"` // 半开时放行的探测请求数，默认 1
+}
+
+// TimeoutConfig 请求超时（毫秒），超时或客户端断开时取消后端调用
+// Rules 按顺序匹配，第一个命中的生效；都不命中时使用 Default，为 0 表示不设置超时
+type TimeoutConfig struct {
+	Default int           `json:"default,omitempty"`
+	Rules   []TimeoutRule `json:"rules,omitempty"`
+}
+
+// TimeoutRule Target 使用与 auth scope 相同的格式，
+// 例如 {"target": "rdb:*:batch", "timeout": 120000}，Timeout 为 0 表示该路由不设置超时
+type TimeoutRule struct {
+	Target  string `json:"target"`
+	Timeout int    `json:"timeout"`
+}
+
+// CORSConfig 跨域策略，未配置时 start 不输出任何 CORS 头，dev 允许任意来源（不带凭证）
+// AllowOrigins 支持精确匹配、"*" 以及 "https://*.example.com" 形式的通配；
+// AllowCredentials 为 true 时不能使用 "*"，响应中回显请求的 Origin
+type CORSConfig struct {
+	AllowOrigins     []string `json:"allowOrigins"`
+	AllowMethods     []string `json:"allowMethods,omitempty"` // 默认 GET, POST, PUT, DELETE, OPTIONS
+	AllowHeaders     []string `json:"allowHeaders,omitempty"` // 默认为网关使用的全部请求头
+	AllowCredentials bool     `json:"allowCredentials,omitempty"`
+	MaxAge           int      `json:"maxAge,omitempty"` // 预检结果缓存时间（秒），0 表示不设置
 }
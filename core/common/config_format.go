@@ -0,0 +1,152 @@
+package combinator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	toml "github.com/pelletier/go-toml/v2"
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// ConfigFormat 配置文件格式
+type ConfigFormat string
+
+const (
+	FormatJSON ConfigFormat = "json"
+	FormatYAML ConfigFormat = "yaml"
+	FormatTOML ConfigFormat = "toml"
+)
+
+// ParseConfigFormat 解析 --format 参数，接受 json、yaml、yml、toml
+func ParseConfigFormat(s string) (ConfigFormat, error) {
+	switch strings.ToLower(s) {
+	case "json":
+		return FormatJSON, nil
+	case "yaml", "yml":
+		return FormatYAML, nil
+	case "toml":
+		return FormatTOML, nil
+	}
+	return "", fmt.Errorf("unknown config format %q (expected json, yaml or toml)", s)
+}
+
+// ConfigFormatFromPath 按扩展名判断格式，无法识别的扩展名视为 JSON
+func ConfigFormatFromPath(path string) ConfigFormat {
+	if f, err := ParseConfigFormat(strings.TrimPrefix(filepath.Ext(path), ".")); err == nil {
+		return f
+	}
+	return FormatJSON
+}
+
+// ConfigToJSON 把 YAML / TOML 配置转换为等价的 JSON，之后统一按 JSON 解码，
+// 因此实例简写、${...} 引用与字段名在所有格式下含义相同
+func ConfigToJSON(data []byte, format ConfigFormat) ([]byte, error) {
+	var tree any
+	switch format {
+	case FormatJSON, "":
+		return data, nil
+	case FormatYAML:
+		if err := yaml.Unmarshal(data, &tree); err != nil {
+			return nil, err
+		}
+	case FormatTOML:
+		if err := toml.Unmarshal(data, &tree); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown config format %q", format)
+	}
+	if tree == nil {
+		// 空文件
+		tree = map[string]any{}
+	}
+	return json.Marshal(tree)
+}
+
+// DecodeConfig 解码任意格式的配置
+func DecodeConfig(data []byte, format ConfigFormat) (*Config, error) {
+	buf, err := ConfigToJSON(data, format)
+	if err != nil {
+		return nil, err
+	}
+	var conf Config
+	if err := json.Unmarshal(buf, &conf); err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// EncodeConfig 按格式编码配置，字段名与 JSON 相同；YAML 保持 JSON 的字段顺序，TOML 按字段名排序
+func EncodeConfig(conf *Config, format ConfigFormat) ([]byte, error) {
+	buf, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatJSON, "":
+		return append(buf, '\n'), nil
+	case FormatYAML:
+		// JSON 是 YAML 的子集，解析为节点可以保留字段顺序
+		var node yaml.Node
+		if err := yaml.Unmarshal(buf, &node); err != nil {
+			return nil, err
+		}
+		blockStyle(&node)
+		var out bytes.Buffer
+		enc := yaml.NewEncoder(&out)
+		enc.SetIndent(2)
+		if err := enc.Encode(&node); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), enc.Close()
+	case FormatTOML:
+		dec := json.NewDecoder(bytes.NewReader(buf))
+		dec.UseNumber()
+		var tree any
+		if err := dec.Decode(&tree); err != nil {
+			return nil, err
+		}
+		return toml.Marshal(tomlValue(tree))
+	}
+	return nil, fmt.Errorf("unknown config format %q", format)
+}
+
+// blockStyle 去掉从 JSON 继承的 flow 风格与引号
+func blockStyle(n *yaml.Node) {
+	n.Style &^= yaml.FlowStyle | yaml.DoubleQuotedStyle
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+// tomlValue TOML 没有 null，删除空值；整数保持为整数
+func tomlValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			if e != nil {
+				out[k] = tomlValue(e)
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, 0, len(v))
+		for _, e := range v {
+			if e != nil {
+				out = append(out, tomlValue(e))
+			}
+		}
+		return out
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
@@ -0,0 +1,88 @@
+package combinator
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestConfigFormats(t *testing.T) {
+	sources := map[ConfigFormat]string{
+		FormatJSON: `{"rdb":["main",{"id":"pg","url":"postgres://u:${PG_PASSWORD}@db/app","metadata":{"maxOpenConns":10}}],"kv":[{"id":"cache","url":"memory://"}],"rateLimit":{"rules":[{"key":"ip","rate":2.5}]}}`,
+		FormatYAML: `
+rdb:
+  - main
+  - id: pg
+    url: postgres://u:${PG_PASSWORD}@db/app
+    metadata:
+      maxOpenConns: 10
+kv:
+  - id: cache
+    url: memory://
+rateLimit:
+  rules:
+    - key: ip
+      rate: 2.5
+`,
+		FormatTOML: `
+rdb = ["main", {id = "pg", url = "postgres://u:${PG_PASSWORD}@db/app", metadata = {maxOpenConns = 10}}]
+
+[[kv]]
+id = "cache"
+url = "memory://"
+
+[[rateLimit.rules]]
+key = "ip"
+rate = 2.5
+`,
+	}
+
+	want, err := DecodeConfig([]byte(sources[FormatJSON]), FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for format, src := range sources {
+		conf, err := DecodeConfig([]byte(src), format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !sameJSON(t, conf, want) {
+			t.Errorf("%s: decoded config differs from JSON", format)
+		}
+
+		// 编码后再解码得到相同的配置
+		buf, err := EncodeConfig(want, format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		back, err := DecodeConfig(buf, format)
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", format, err, buf)
+		}
+		if !sameJSON(t, back, want) {
+			t.Errorf("%s: round trip differs:\n%s", format, buf)
+		}
+	}
+
+	for path, want := range map[string]ConfigFormat{"a.json": FormatJSON, "a.YML": FormatYAML, "a.toml": FormatTOML, "a.conf": FormatJSON} {
+		if got := ConfigFormatFromPath(path); got != want {
+			t.Errorf("ConfigFormatFromPath(%q) = %s, want %s", path, got, want)
+		}
+	}
+}
+
+func sameJSON(t *testing.T, a, b *Config) bool {
+	t.Helper()
+	var x, y any
+	for _, p := range []struct {
+		conf *Config
+		out  *any
+	}{{a, &x}, {b, &y}} {
+		buf, err := json.Marshal(p.conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		json.Unmarshal(buf, p.out)
+	}
+	return reflect.DeepEqual(x, y)
+}
@@ -0,0 +1,43 @@
+package combinator
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrReadOnly 对只读实例执行写操作
+	ErrReadOnly = errors.New("instance is read-only")
+	// ErrAppendOnly 对只追加实例执行修改或删除
+	ErrAppendOnly = errors.New("instance is append-only")
+	// ErrValueTooLarge 写入的值超过实例的 maxValueSize
+	ErrValueTooLarge = errors.New("value exceeds maxValueSize")
+	// ErrInvalidKey key 会离开实例的 keyPrefix 或存储目录，例如 "../other/x"
+	ErrInvalidKey = errors.New("invalid key")
+	// ErrTTLUnsupported 后端不支持按 key 过期（rocksdb）
+	ErrTTLUnsupported = errors.New("instance does not support key expiry")
+)
+
+// StatusClientClosedRequest 客户端在响应前断开（沿用 nginx 的 499）
+const StatusClientClosedRequest = 499
+
+// ErrorStatus 后端调用失败时的 HTTP 状态码：
+// 非法 key 400，访问模式不允许 403，值过大 413，熔断 503，超时 504，客户端断开 499，其余 500
+func ErrorStatus(err error) int {
+	var open *BreakerOpenError
+	switch {
+	case errors.Is(err, ErrInvalidKey):
+		return 400
+	case errors.Is(err, ErrReadOnly), errors.Is(err, ErrAppendOnly):
+		return 403
+	case errors.Is(err, ErrValueTooLarge):
+		return 413
+	case errors.As(err, &open):
+		return 503
+	case errors.Is(err, context.DeadlineExceeded):
+		return 504
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest
+	}
+	return 500
+}
@@ -0,0 +1,107 @@
+package combinator
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandConfig(t *testing.T) {
+	t.Setenv("COMB_TEST_USER", "app")
+	secret := filepath.Join(t.TempDir(), "pg")
+	if err := os.WriteFile(secret, []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	rawConf := &Config{
+		Rdb: []RDBConfig{{ID: "pg", URL: "postgres://${COMB_TEST_USER}:${file:" + secret + "}@db/app?note=$${x}"}},
+		Kv:  []KVConfig{{ID: "m", URL: "memory://", Metadata: map[string]any{"keyPrefix": "${COMB_TEST_USER}:"}}},
+	}
+	conf, err := ExpandConfig(rawConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := conf.Rdb[0].URL; got != "postgres://app:s3cr3t@db/app?note=${x}" {
+		t.Errorf("unexpected url: %s", got)
+	}
+	// 只展开实例 URL
+	if got := conf.Kv[0].Metadata.(map[string]any)["keyPrefix"]; got != "${COMB_TEST_USER}:" {
+		t.Errorf("metadata should not be expanded: %v", got)
+	}
+	if !strings.Contains(rawConf.Rdb[0].URL, "${COMB_TEST_USER}") {
+		t.Error("ExpandConfig modified its input")
+	}
+
+	_, err = ExpandConfig(&Config{S3: []S3Config{{ID: "b", URL: "minio://${COMB_TEST_MISSING}@h/b"}}})
+	if err == nil || !strings.Contains(err.Error(), "s3[0].url: environment variable COMB_TEST_MISSING is not set") {
+		t.Errorf("expected missing variable error, got %v", err)
+	}
+
+	// 通过 API 提交的配置不能引入新的 ${file:}
+	current := &Config{Rdb: []RDBConfig{{ID: "pg", URL: "postgres://app:${file:/run/secrets/pg}@db/app"}}}
+	if err := CheckFileRefs(current, current); err != nil {
+		t.Errorf("existing file reference rejected: %v", err)
+	}
+	posted := &Config{Kv: []KVConfig{{ID: "k", URL: "redis://:${file:/etc/shadow}@h/0"}}}
+	if err := CheckFileRefs(posted, current); err == nil || !strings.Contains(err.Error(), "kv[0].url") {
+		t.Errorf("expected new file reference to be rejected, got %v", err)
+	}
+
+	// 错误信息中不出现展开后的值
+	t.Setenv("COMB_TEST_SECRET", "EnvSecret-456")
+	raw := "${COMB_TEST_SECRET}://x"
+	err = HideResolved(InvalidURL(errors.New("unsupported KV store type: envsecret-456")), raw)
+	if strings.Contains(strings.ToLower(err.Error()), "envsecret") || !strings.Contains(err.Error(), raw) {
+		t.Errorf("resolved value leaked: %v", err)
+	}
+	err = HideResolved(errors.New("dial tcp envsecret-456:6379: refused"), "redis://${COMB_TEST_SECRET}:6379")
+	if err.Error() != "dial tcp ${COMB_TEST_SECRET}:6379: refused" {
+		t.Errorf("resolved value leaked: %v", err)
+	}
+
+	for in, want := range map[string]string{
+		"postgres://app:s3cr3t@db/app":               "postgres://app:xxxxx@db/app",
+		"postgres://app:${PG_PASSWORD}@db/app":       "postgres://app:${PG_PASSWORD}@db/app",
+		"redis://:pw@localhost:6379/0":               "redis://:xxxxx@localhost:6379/0",
+		"postgres://db/app?user=a&password=p&ssl=on": "postgres://db/app?user=a&password=xxxxx&ssl=on",
+		"sqlite:///tmp/a.db":                         "sqlite:///tmp/a.db",
+	} {
+		if got := RedactURL(in); got != want {
+			t.Errorf("RedactURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestShorthandInstances(t *testing.T) {
+	var conf Config
+	in := `{"rdb":["main",{"id":"pg","url":"postgres://db/app"}],"kv":["cache"],"s3":[{"id":"files"}]}`
+	if err := json.Unmarshal([]byte(in), &conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Rdb[0].ID != "main" || conf.Rdb[0].URL != "" || conf.Rdb[1].URL != "postgres://db/app" {
+		t.Errorf("unexpected rdb: %+v", conf.Rdb)
+	}
+
+	// 只写 ID 的条目写回时仍为字符串
+	out, err := json.Marshal(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"rdb":["main",{"id":"pg","url":"postgres://db/app"}],"kv":["cache"],"s3":["files"]`; !strings.Contains(string(out), want) {
+		t.Errorf("unexpected round trip: %s", out)
+	}
+
+	resolved, err := ExpandConfig(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Rdb[0].URL != "sqlite://main.sqlite" || resolved.Kv[0].URL != "memory://" || resolved.S3[0].URL != "local://s3/files" {
+		t.Errorf("unexpected default urls: %+v %+v %+v", resolved.Rdb, resolved.Kv, resolved.S3)
+	}
+	if conf.Rdb[0].URL != "" {
+		t.Error("ExpandConfig modified its input")
+	}
+}
@@ -1,6 +1,7 @@
 package combinator
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -43,6 +44,19 @@ func (eb *ErrorBuilder) Error(msg string, args ...any) error {
 	return fmt.Errorf("%s", eb.String(msg, args...))
 }
 
+// Wrap 与 Error 相同，但保留 err 以便 errors.Is / errors.As 判断
+func (eb *ErrorBuilder) Wrap(err error, msg string, args ...any) error {
+	return fmt.Errorf("%s: %w", eb.String(msg, args...), err)
+}
+
+// 结构化日志的公共字段名
+const (
+	FieldRequestID = "request_id"
+	FieldKind      = "kind"
+	FieldInstance  = "instance"
+	FieldOp        = "op"
+)
+
 func init() {
 	Logger = logrus.New()
 
@@ -50,7 +64,7 @@ func init() {
 	Logger.SetOutput(os.Stdout)
 
 	// Set log level
-	Logger.SetLevel(logrus.DebugLevel)
+	Logger.SetLevel(logrus.InfoLevel)
 
 	// Set formatter
 	Logger.SetFormatter(&logrus.TextFormatter{
@@ -59,8 +73,8 @@ func init() {
 	})
 }
 
-// SetLogLevel sets the global log level
-func SetLogLevel(level string) {
+// SetLogLevel sets the global log level: debug, info, warn or error
+func SetLogLevel(level string) error {
 	switch level {
 	case "debug":
 		Logger.SetLevel(logrus.DebugLevel)
@@ -71,6 +85,45 @@ func SetLogLevel(level string) {
 	case "error":
 		Logger.SetLevel(logrus.ErrorLevel)
 	default:
-		Logger.SetLevel(logrus.InfoLevel)
+		return fmt.Errorf("unknown log level: %s", level)
+	}
+	return nil
+}
+
+// SetLogFormat sets the global log output format: text or json
+func SetLogFormat(format string) error {
+	switch format {
+	case "text":
+		Logger.SetFormatter(&logrus.TextFormatter{
+			FullTimestamp:   true,
+			TimestampFormat: "2006-01-02 15:04:05",
+		})
+	case "json":
+		Logger.SetFormatter(&logrus.JSONFormatter{
+			TimestampFormat: "2006-01-02T15:04:05.000Z07:00",
+		})
+	default:
+		return fmt.Errorf("unknown log format: %s", format)
 	}
+	return nil
+}
+
+type logEntryKey struct{}
+
+// WithLogFields 返回携带日志字段的 context，之后 Log(ctx) 输出的每条日志都带上这些字段
+func WithLogFields(ctx context.Context, fields logrus.Fields) context.Context {
+	return context.WithValue(ctx, logEntryKey{}, Log(ctx).WithFields(fields))
+}
+
+// Log 返回 ctx 中携带的日志条目（例如请求 ID、实例 ID），没有时使用全局 Logger
+func Log(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(logEntryKey{}).(*logrus.Entry); ok {
+		return entry
+	}
+	return logrus.NewEntry(Logger)
+}
+
+// InstanceLog 返回带 kind 与实例 ID 字段的日志条目，用于与请求无关的实例日志
+func InstanceLog(kind, id string) *logrus.Entry {
+	return Logger.WithFields(logrus.Fields{FieldKind: kind, FieldInstance: id})
 }
@@ -0,0 +1,453 @@
+package combinator
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// InstanceConfig 可由 Registry 管理的实例配置
+type InstanceConfig[C any] interface {
+	InstanceID() string
+	// Same 报告两份配置是否描述同一个实例，相同时 reload 会保留旧实例
+	Same(other C) bool
+}
+
+// BuildFunc 根据配置创建并启动实例
+type BuildFunc[C any, S Service] func(conf C) (S, error)
+
+// ErrInstanceNotFound 实例未配置
+var ErrInstanceNotFound = errors.New("instance not found")
+
+// LookupStatus 把 Lookup 返回的错误转换为 HTTP 状态码：未配置为 400，不可用为 503
+func LookupStatus(err error) int {
+	var unavailable *UnavailableError
+	if errors.As(err, &unavailable) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+// registrySnapshot 不可变的实例表，读路径直接使用，不加锁
+type registrySnapshot[C any, S Service] struct {
+	gen         int64 // 每次 Commit / Close 递增，后台重试成功不递增
+	instances   map[string]S
+	unavailable map[string]*pendingInstance[C] // 启动失败、正在后台重试的实例
+	configs     map[string]C
+	order       []string // 配置中的顺序
+}
+
+// withPromoted 返回把不可用实例替换为已启动实例后的快照副本
+func (s *registrySnapshot[C, S]) withPromoted(id string, inst S) *registrySnapshot[C, S] {
+	next := &registrySnapshot[C, S]{
+		gen:         s.gen,
+		instances:   maps.Clone(s.instances),
+		unavailable: maps.Clone(s.unavailable),
+		configs:     s.configs,
+		order:       s.order,
+	}
+	delete(next.unavailable, id)
+	next.instances[id] = inst
+	return next
+}
+
+// Registry 并发安全的服务实例表
+// 读取通过原子加载快照完成，不持有锁；写入通过 CompareAndSwap 替换整个快照
+type Registry[C InstanceConfig[C], S Service] struct {
+	kind    string
+	build   BuildFunc[C, S]
+	wrap    func(id string, s S) S
+	backoff Backoff
+	snap    atomic.Pointer[registrySnapshot[C, S]]
+}
+
+func NewRegistry[C InstanceConfig[C], S Service](kind string, build BuildFunc[C, S]) *Registry[C, S] {
+	r := &Registry[C, S]{kind: kind, build: build, backoff: DefaultBackoff}
+	r.snap.Store(emptySnapshot[C, S](0))
+	return r
+}
+
+func emptySnapshot[C any, S Service](gen int64) *registrySnapshot[C, S] {
+	return &registrySnapshot[C, S]{
+		gen:         gen,
+		instances:   make(map[string]S),
+		unavailable: make(map[string]*pendingInstance[C]),
+		configs:     make(map[string]C),
+	}
+}
+
+// SetBackoff 设置不可用实例的重试间隔
+func (r *Registry[C, S]) SetBackoff(b Backoff) {
+	r.backoff = b
+}
+
+// SetWrapper 设置实例启动成功后的包装，例如熔断器，需在加载配置之前调用
+func (r *Registry[C, S]) SetWrapper(wrap func(id string, s S) S) {
+	r.wrap = wrap
+}
+
+// start 启动实例并套上包装
+func (r *Registry[C, S]) start(conf C) (S, error) {
+	s, err := r.build(conf)
+	if err != nil || r.wrap == nil {
+		return s, err
+	}
+	return r.wrap(conf.InstanceID(), s), nil
+}
+
+// Get 返回指定 ID 的可用实例
+func (r *Registry[C, S]) Get(id string) (S, bool) {
+	s, ok := r.snap.Load().instances[id]
+	return s, ok
+}
+
+// Lookup 返回指定 ID 的实例；未配置时返回 ErrInstanceNotFound，
+// 已配置但尚未启动成功时返回 *UnavailableError
+func (r *Registry[C, S]) Lookup(id string) (S, error) {
+	snap := r.snap.Load()
+	if s, ok := snap.instances[id]; ok {
+		return s, nil
+	}
+	var zero S
+	if p, ok := snap.unavailable[id]; ok {
+		return zero, p.status(r.kind, id)
+	}
+	return zero, fmt.Errorf("%s %s: %w", r.kind, id, ErrInstanceNotFound)
+}
+
+// Has reports whether an instance with the given ID is configured, available or not
+func (r *Registry[C, S]) Has(id string) bool {
+	_, ok := r.snap.Load().configs[id]
+	return ok
+}
+
+// Count returns the number of available instances
+func (r *Registry[C, S]) Count() int {
+	return len(r.snap.Load().instances)
+}
+
+// Config 返回指定 ID 当前生效的配置
+func (r *Registry[C, S]) Config(id string) (C, bool) {
+	c, ok := r.snap.Load().configs[id]
+	return c, ok
+}
+
+// Configs 按配置顺序返回当前生效的配置
+func (r *Registry[C, S]) Configs() []C {
+	snap := r.snap.Load()
+	confs := make([]C, 0, len(snap.order))
+	for _, id := range snap.order {
+		confs = append(confs, snap.configs[id])
+	}
+	return confs
+}
+
+// Range 按配置顺序遍历当前可用的实例，fn 返回 false 时停止
+func (r *Registry[C, S]) Range(fn func(id string, s S) bool) {
+	snap := r.snap.Load()
+	for _, id := range snap.order {
+		s, ok := snap.instances[id]
+		if !ok {
+			continue
+		}
+		if !fn(id, s) {
+			return
+		}
+	}
+}
+
+// Unavailable 按配置顺序返回所有不可用实例的状态
+func (r *Registry[C, S]) Unavailable() []*UnavailableError {
+	snap := r.snap.Load()
+	var out []*UnavailableError
+	for _, id := range snap.order {
+		if p, ok := snap.unavailable[id]; ok {
+			out = append(out, p.status(r.kind, id))
+		}
+	}
+	return out
+}
+
+// RegistryPlan 两阶段重载中已准备好的实例
+// Prepare 只启动新增或变化的实例，不影响正在服务的实例
+type RegistryPlan[C any, S Service] struct {
+	base    *registrySnapshot[C, S]
+	next    *registrySnapshot[C, S]
+	started map[string]S // 本次新启动的实例，Abort 时关闭
+	retired map[string]S // 提交后需要关闭的旧实例
+	kind    string
+	Results []InstanceResult
+}
+
+// Abort 丢弃计划，关闭本次新启动的实例
+func (p *RegistryPlan[C, S]) Abort() {
+	for id, s := range p.started {
+		if err := s.Close(); err != nil {
+			InstanceLog(p.kind, id).Warnf("Failed to close prepared %s %s: %v", strings.ToUpper(p.kind), id, err)
+		}
+	}
+	p.started = nil
+	for id, pending := range p.next.unavailable {
+		if p.base.unavailable[id] != pending {
+			pending.cancel()
+		}
+	}
+}
+
+func (p *RegistryPlan[C, S]) record(id string, action ReloadAction, err error) {
+	res := InstanceResult{Kind: p.kind, ID: id, Action: action}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	p.Results = append(p.Results, res)
+}
+
+// Prepare 第一阶段：启动所有新增或变化的实例，任一失败时返回错误，
+// 但会尝试所有实例以便报告每个实例的结果
+func (r *Registry[C, S]) Prepare(newConf []C) (*RegistryPlan[C, S], error) {
+	return r.prepare(newConf, false)
+}
+
+// prepare degraded 为 true 时，启动失败的实例登记为不可用而不是返回错误
+func (r *Registry[C, S]) prepare(newConf []C, degraded bool) (*RegistryPlan[C, S], error) {
+	base := r.snap.Load()
+	plan := &RegistryPlan[C, S]{
+		base:    base,
+		next:    emptySnapshot[C, S](base.gen + 1),
+		started: make(map[string]S),
+		retired: make(map[string]S),
+		kind:    r.kind,
+	}
+	name := strings.ToUpper(r.kind)
+
+	var errs []error
+	for _, conf := range newConf {
+		id := conf.InstanceID()
+		if _, dup := plan.next.configs[id]; dup {
+			err := fmt.Errorf("duplicate %s id: %s", name, id)
+			plan.record(id, ReloadFailed, err)
+			errs = append(errs, err)
+			continue
+		}
+		plan.next.configs[id] = conf
+		plan.next.order = append(plan.next.order, id)
+
+		oldConf, configured := base.configs[id]
+		old, exists := base.instances[id]
+		if configured && oldConf.Same(conf) {
+			if exists {
+				plan.next.instances[id] = old
+			} else {
+				// 仍在后台重试，继续沿用
+				plan.next.unavailable[id] = base.unavailable[id]
+			}
+			plan.record(id, ReloadUnchanged, nil)
+			continue
+		}
+
+		s, err := r.start(conf)
+		if err != nil {
+			InstanceLog(r.kind, id).Errorf("Failed to start %s %s: %v", name, id, err)
+			plan.record(id, ReloadFailed, err)
+			if degraded {
+				plan.next.unavailable[id] = newPendingInstance(conf, err)
+				continue
+			}
+			errs = append(errs, fmt.Errorf("%s %s: %w", name, id, err))
+			continue
+		}
+		InstanceLog(r.kind, id).Infof("Prepared %s %s: %s", s.Type(), name, id)
+
+		action := ReloadAdded
+		if configured {
+			action = ReloadReplaced
+		}
+		if exists {
+			plan.retired[id] = old
+		}
+		plan.next.instances[id] = s
+		plan.started[id] = s
+		plan.record(id, action, nil)
+	}
+
+	for _, id := range base.order {
+		if _, ok := plan.next.configs[id]; !ok {
+			if old, ok := base.instances[id]; ok {
+				plan.retired[id] = old
+			}
+			plan.record(id, ReloadRemoved, nil)
+		}
+	}
+
+	return plan, errors.Join(errs...)
+}
+
+// Diff 按 Prepare 的规则计算 newConf 相对当前实例表的变化，不启动也不关闭任何实例，用于 dry run
+func (r *Registry[C, S]) Diff(newConf []C) []InstanceResult {
+	base := r.snap.Load()
+	plan := &RegistryPlan[C, S]{kind: r.kind}
+	seen := make(map[string]bool)
+	for _, conf := range newConf {
+		id := conf.InstanceID()
+		if seen[id] {
+			plan.record(id, ReloadFailed, fmt.Errorf("duplicate %s id: %s", strings.ToUpper(r.kind), id))
+			continue
+		}
+		seen[id] = true
+
+		oldConf, configured := base.configs[id]
+		switch {
+		case configured && oldConf.Same(conf):
+			plan.record(id, ReloadUnchanged, nil)
+		case configured:
+			plan.record(id, ReloadReplaced, nil)
+		default:
+			plan.record(id, ReloadAdded, nil)
+		}
+	}
+	for _, id := range base.order {
+		if !seen[id] {
+			plan.record(id, ReloadRemoved, nil)
+		}
+	}
+	return plan.Results
+}
+
+// Commit 第二阶段：替换快照并关闭被替换或删除的旧实例
+// 如果准备之后实例表已被其他写入修改，放弃计划并返回错误；
+// 期间仅有后台重试成功时，把重试启动的实例合并进新快照
+func (r *Registry[C, S]) Commit(plan *RegistryPlan[C, S]) error {
+	for {
+		cur := r.snap.Load()
+		next := plan.next
+		var extra []S
+		if cur != plan.base {
+			if cur.gen != plan.base.gen {
+				plan.Abort()
+				return fmt.Errorf("%s registry changed during reload", r.kind)
+			}
+			next, extra = rebase(plan.base, cur, plan.next)
+		}
+		if !r.snap.CompareAndSwap(cur, next) {
+			continue
+		}
+
+		// 此时新快照已生效，新请求不会再拿到旧实例
+		for id, s := range plan.retired {
+			r.closeInstance(id, s)
+		}
+		for _, s := range extra {
+			if err := s.Close(); err != nil {
+				Logger.Warnf("Failed to close %s instance: %v", strings.ToUpper(r.kind), err)
+			}
+		}
+		r.syncRetries(plan.base, next)
+		plan.started = nil
+		return nil
+	}
+}
+
+// rebase 把 base 之后由后台重试启动的实例合并进 next；
+// next 中已不再需要的这些实例作为 extra 返回，由调用方关闭
+func rebase[C any, S Service](base, cur, next *registrySnapshot[C, S]) (*registrySnapshot[C, S], []S) {
+	out := next
+	var extra []S
+	for id, pending := range base.unavailable {
+		inst, promoted := cur.instances[id]
+		if !promoted {
+			continue
+		}
+		if next.unavailable[id] == pending {
+			if out == next {
+				out = &registrySnapshot[C, S]{
+					gen:         next.gen,
+					instances:   maps.Clone(next.instances),
+					unavailable: maps.Clone(next.unavailable),
+					configs:     next.configs,
+					order:       next.order,
+				}
+			}
+			delete(out.unavailable, id)
+			out.instances[id] = inst
+		} else {
+			extra = append(extra, inst)
+		}
+	}
+	return out, extra
+}
+
+// syncRetries 为新登记的不可用实例启动重试，停止已移除的重试
+func (r *Registry[C, S]) syncRetries(old, next *registrySnapshot[C, S]) {
+	for id, pending := range old.unavailable {
+		if next.unavailable[id] != pending {
+			pending.cancel()
+		}
+	}
+	for id, pending := range next.unavailable {
+		if old.unavailable[id] != pending {
+			go r.retry(id, pending)
+		}
+	}
+}
+
+func (r *Registry[C, S]) closeInstance(id string, s S) {
+	if err := s.Close(); err != nil {
+		InstanceLog(r.kind, id).Warnf("Failed to close %s %s: %v", strings.ToUpper(r.kind), id, err)
+	}
+	InstanceLog(r.kind, id).Infof("Closed %s %s", strings.ToUpper(r.kind), id)
+}
+
+// Reload 准备并提交新配置，失败时保持旧实例不变
+func (r *Registry[C, S]) Reload(newConf []C) error {
+	plan, err := r.Prepare(newConf)
+	if err != nil {
+		plan.Abort()
+		return err
+	}
+	return r.Commit(plan)
+}
+
+// Load 用于启动：启动失败的实例登记为不可用并在后台按退避重试，
+// 只有配置本身的错误（如重复 ID）才返回错误
+func (r *Registry[C, S]) Load(newConf []C) error {
+	plan, err := r.prepare(newConf, true)
+	if err != nil {
+		plan.Abort()
+		return err
+	}
+	for id := range plan.next.unavailable {
+		InstanceLog(r.kind, id).Warnf("%s %s is unavailable, retrying in background", strings.ToUpper(r.kind), id)
+	}
+	return r.Commit(plan)
+}
+
+// Close 清空实例表并关闭所有实例，返回所有关闭失败
+func (r *Registry[C, S]) Close() error {
+	var old *registrySnapshot[C, S]
+	for {
+		old = r.snap.Load()
+		if r.snap.CompareAndSwap(old, emptySnapshot[C, S](old.gen+1)) {
+			break
+		}
+	}
+	for _, pending := range old.unavailable {
+		pending.cancel()
+	}
+
+	var errs []error
+	for _, id := range old.order {
+		s, ok := old.instances[id]
+		if !ok {
+			continue
+		}
+		if err := s.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close %s %s: %w", strings.ToUpper(r.kind), id, err))
+			continue
+		}
+		InstanceLog(r.kind, id).Infof("Closed %s %s", strings.ToUpper(r.kind), id)
+	}
+	return errors.Join(errs...)
+}
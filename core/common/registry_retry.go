@@ -0,0 +1,142 @@
+package combinator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backoff 指数退避参数
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// DefaultBackoff 1s 起，每次翻倍，最长 1 分钟
+var DefaultBackoff = Backoff{Initial: time.Second, Max: time.Minute}
+
+// next 返回下一次等待时间
+func (b Backoff) next(d time.Duration) time.Duration {
+	if d <= 0 {
+		return b.Initial
+	}
+	d *= 2
+	if d > b.Max {
+		d = b.Max
+	}
+	return d
+}
+
+// UnavailableError 已配置但启动失败、正在后台重试的实例
+type UnavailableError struct {
+	Kind      string    `json:"kind"`
+	ID        string    `json:"id"`
+	Cause     string    `json:"error"`
+	Since     time.Time `json:"since"`
+	Attempts  int       `json:"attempts"`
+	NextRetry time.Time `json:"nextRetry,omitzero"`
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("%s %s is unavailable: %s", strings.ToUpper(e.Kind), e.ID, e.Cause)
+}
+
+// pendingInstance 不可用实例的重试状态
+type pendingInstance[C any] struct {
+	conf   C
+	since  time.Time
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	err       error
+	attempts  int
+	nextRetry time.Time
+}
+
+func newPendingInstance[C any](conf C, err error) *pendingInstance[C] {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &pendingInstance[C]{
+		conf:     conf,
+		since:    time.Now(),
+		ctx:      ctx,
+		cancel:   cancel,
+		err:      err,
+		attempts: 1,
+	}
+}
+
+func (p *pendingInstance[C]) status(kind, id string) *UnavailableError {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return &UnavailableError{
+		Kind:      kind,
+		ID:        id,
+		Cause:     p.err.Error(),
+		Since:     p.since,
+		Attempts:  p.attempts,
+		NextRetry: p.nextRetry,
+	}
+}
+
+func (p *pendingInstance[C]) scheduled(at time.Time) {
+	p.mu.Lock()
+	p.nextRetry = at
+	p.mu.Unlock()
+}
+
+func (p *pendingInstance[C]) failed(err error) {
+	p.mu.Lock()
+	p.err = err
+	p.attempts++
+	p.mu.Unlock()
+}
+
+// retry 按退避重试启动实例，成功后替换快照中的不可用条目
+// 条目被 reload 移除或替换、或 Registry 关闭时停止
+func (r *Registry[C, S]) retry(id string, p *pendingInstance[C]) {
+	name := strings.ToUpper(r.kind)
+	var delay time.Duration
+	for {
+		delay = r.backoff.next(delay)
+		p.scheduled(time.Now().Add(delay))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-p.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s, err := r.start(p.conf)
+		if err != nil {
+			p.failed(err)
+			InstanceLog(r.kind, id).Debugf("Retry %s %s failed: %v", name, id, err)
+			continue
+		}
+		if r.promote(id, p, s) {
+			InstanceLog(r.kind, id).Infof("%s %s is available after %d attempts", name, id, p.status(r.kind, id).Attempts+1)
+		}
+		return
+	}
+}
+
+// promote 把重试成功的实例放入快照，条目已不在快照中时关闭实例
+func (r *Registry[C, S]) promote(id string, p *pendingInstance[C], s S) bool {
+	for {
+		cur := r.snap.Load()
+		if cur.unavailable[id] != p {
+			if err := s.Close(); err != nil {
+				InstanceLog(r.kind, id).Warnf("Failed to close %s %s: %v", strings.ToUpper(r.kind), id, err)
+			}
+			return false
+		}
+		if r.snap.CompareAndSwap(cur, cur.withPromoted(id, s)) {
+			p.cancel()
+			return true
+		}
+	}
+}
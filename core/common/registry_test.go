@@ -0,0 +1,216 @@
+package combinator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeService struct {
+	url    string
+	closed atomic.Bool
+}
+
+func (f *fakeService) Start() error { return nil }
+func (f *fakeService) Close() error {
+	f.closed.Store(true)
+	return nil
+}
+func (f *fakeService) Type() string                   { return "fake" }
+func (f *fakeService) Ping(ctx context.Context) error { return nil }
+
+func newFakeRegistry() *Registry[KVConfig, *fakeService] {
+	return NewRegistry("kv", func(c KVConfig) (*fakeService, error) {
+		if c.URL == "fail://" {
+			return nil, errors.New("start failed")
+		}
+		return &fakeService{url: c.URL}, nil
+	})
+}
+
+func TestRegistryReloadDiff(t *testing.T) {
+	reg := newFakeRegistry()
+	if err := reg.Reload([]KVConfig{{ID: "a", URL: "m://1"}, {ID: "b", URL: "m://1"}}); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := reg.Get("a")
+	b, _ := reg.Get("b")
+
+	plan, err := reg.Prepare([]KVConfig{{ID: "a", URL: "m://1"}, {ID: "b", URL: "m://2"}, {ID: "c", URL: "m://1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]ReloadAction{"a": ReloadUnchanged, "b": ReloadReplaced, "c": ReloadAdded}
+	for _, res := range plan.Results {
+		if want[res.ID] != res.Action {
+			t.Errorf("%s: got %s, want %s", res.ID, res.Action, want[res.ID])
+		}
+	}
+	if err := reg.Commit(plan); err != nil {
+		t.Fatal(err)
+	}
+	if a2, _ := reg.Get("a"); a2 != a {
+		t.Error("unchanged instance was replaced")
+	}
+	if !b.closed.Load() {
+		t.Error("replaced instance was not closed")
+	}
+
+	// Metadata 变化同样视为替换
+	plan, _ = reg.Prepare([]KVConfig{{ID: "a", URL: "m://1", Metadata: map[string]any{"keyPrefix": "x:"}}})
+	if plan.Results[0].Action != ReloadReplaced {
+		t.Errorf("metadata change: got %s, want replaced", plan.Results[0].Action)
+	}
+	plan.Abort()
+
+	// 失败时保持旧实例，关闭新启动的实例
+	plan, err = reg.Prepare([]KVConfig{{ID: "d", URL: "m://1"}, {ID: "e", URL: "fail://"}})
+	if err == nil {
+		t.Fatal("expected prepare error")
+	}
+	d := plan.started["d"]
+	plan.Abort()
+	if !d.closed.Load() {
+		t.Error("prepared instance was not closed on abort")
+	}
+	if reg.Count() != 3 || !reg.Has("c") {
+		t.Errorf("registry changed after failed reload: %d instances", reg.Count())
+	}
+}
+
+// Diff 与 Prepare 给出相同的结果，但不启动实例
+func TestRegistryDryRunDiff(t *testing.T) {
+	var started atomic.Int32
+	reg := NewRegistry("kv", func(c KVConfig) (*fakeService, error) {
+		started.Add(1)
+		return &fakeService{url: c.URL}, nil
+	})
+	if err := reg.Reload([]KVConfig{{ID: "a", URL: "m://1"}, {ID: "b", URL: "m://1"}, {ID: "gone", URL: "m://1"}}); err != nil {
+		t.Fatal(err)
+	}
+	started.Store(0)
+
+	got := reg.Diff([]KVConfig{{ID: "a", URL: "m://1"}, {ID: "b", URL: "m://2"}, {ID: "c", URL: "m://1"}, {ID: "c", URL: "m://1"}})
+	want := []ReloadAction{ReloadUnchanged, ReloadReplaced, ReloadAdded, ReloadFailed, ReloadRemoved}
+	if len(got) != len(want) {
+		t.Fatalf("got %d results, want %d: %+v", len(got), len(want), got)
+	}
+	for i, res := range got {
+		if res.Action != want[i] {
+			t.Errorf("%s: got %s, want %s", res.ID, res.Action, want[i])
+		}
+	}
+	if started.Load() != 0 || reg.Count() != 3 || !reg.Has("gone") {
+		t.Error("Diff changed the registry")
+	}
+}
+
+func TestRegistryStaleCommit(t *testing.T) {
+	reg := newFakeRegistry()
+	plan1, _ := reg.Prepare([]KVConfig{{ID: "a", URL: "m://1"}})
+	plan2, _ := reg.Prepare([]KVConfig{{ID: "b", URL: "m://1"}})
+	if err := reg.Commit(plan1); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Commit(plan2); err == nil {
+		t.Fatal("expected stale plan to be rejected")
+	}
+	if !reg.Has("a") || reg.Has("b") {
+		t.Error("stale plan was applied")
+	}
+}
+
+// 使用 -race 运行：读路径与重载并发时不应出现数据竞争，
+// 读到的实例要么来自旧快照要么来自新快照，且配置中的 ID 始终可见
+func TestRegistryConcurrentReload(t *testing.T) {
+	reg := newFakeRegistry()
+	if err := reg.Reload([]KVConfig{{ID: "stable", URL: "m://0"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	var misses atomic.Int64
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if s, ok := reg.Get("stable"); !ok || s.url == "" {
+					misses.Add(1)
+				}
+				reg.Range(func(id string, s *fakeService) bool { return s.Type() == "fake" })
+				_ = reg.Configs()
+			}
+		}()
+	}
+
+	for i := 0; i < 200; i++ {
+		confs := []KVConfig{
+			{ID: "stable", URL: fmt.Sprintf("m://%d", i%3)},
+			{ID: fmt.Sprintf("tmp%d", i%5), URL: "m://1"},
+		}
+		if err := reg.Reload(confs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if n := misses.Load(); n != 0 {
+		t.Errorf("stable instance missing in %d reads", n)
+	}
+	if err := reg.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if reg.Count() != 0 {
+		t.Error("registry not empty after close")
+	}
+}
+
+func TestRegistryDegradedLoad(t *testing.T) {
+	var down atomic.Bool
+	down.Store(true)
+	reg := NewRegistry("kv", func(c KVConfig) (*fakeService, error) {
+		if c.URL == "flaky://" && down.Load() {
+			return nil, errors.New("connection refused")
+		}
+		return &fakeService{url: c.URL}, nil
+	})
+	reg.SetBackoff(Backoff{Initial: time.Millisecond, Max: 5 * time.Millisecond})
+
+	if err := reg.Load([]KVConfig{{ID: "a", URL: "m://1"}, {ID: "b", URL: "flaky://"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !reg.Has("b") || reg.Count() != 1 {
+		t.Fatalf("want b configured but unavailable, count=%d", reg.Count())
+	}
+	var unavailable *UnavailableError
+	if _, err := reg.Lookup("b"); !errors.As(err, &unavailable) || LookupStatus(err) != 503 {
+		t.Fatalf("lookup b: %v", err)
+	}
+
+	down.Store(false)
+	deadline := time.Now().Add(2 * time.Second)
+	for len(reg.Unavailable()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("instance was not promoted after recovery")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := reg.Get("b"); !ok {
+		t.Error("promoted instance not visible")
+	}
+	if err := reg.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
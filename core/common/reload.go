@@ -0,0 +1,37 @@
+package combinator
+
+// ReloadAction 重载后单个实例的状态
+type ReloadAction string
+
+const (
+	ReloadUnchanged ReloadAction = "unchanged"
+	ReloadAdded     ReloadAction = "added"
+	ReloadReplaced  ReloadAction = "replaced"
+	ReloadRemoved   ReloadAction = "removed"
+	ReloadFailed    ReloadAction = "failed"
+)
+
+// InstanceResult 单个实例的重载结果
+type InstanceResult struct {
+	Kind   string       `json:"kind"`
+	ID     string       `json:"id"`
+	Action ReloadAction `json:"action"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// ReloadResult 一次重载的整体结果，Applied 为 false 时仍在使用旧配置；
+// DryRun 为 true 时只是预计的变化，没有启动或关闭任何实例
+type ReloadResult struct {
+	Applied   bool             `json:"applied"`
+	DryRun    bool             `json:"dryRun,omitempty"`
+	Instances []InstanceResult `json:"instances"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// ReloadRequest 提交给重载循环的新配置，Source 标明来源（file、api 等）；
+// Result 非空时重载循环把结果发送给它，通道需要有缓冲
+type ReloadRequest struct {
+	Config *Config
+	Source string
+	Result chan<- *ReloadResult
+}
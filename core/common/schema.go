@@ -0,0 +1,103 @@
+package combinator
+
+import (
+	"reflect"
+	"strings"
+)
+
+// SchemaID config.schema.json 的 $id
+const SchemaID = "https://github.com/jabberwocky238/combinator/config.schema.json"
+
+// shorthandTypes 可以只写 ID 字符串的实例条目
+var shorthandTypes = map[reflect.Type]bool{
+	reflect.TypeOf(RDBConfig{}): true,
+	reflect.TypeOf(KVConfig{}):  true,
+	reflect.TypeOf(S3Config{}):  true,
+}
+
+// ConfigSchema 根据 Config 的 Go 类型生成 JSON Schema (draft 2020-12)，
+// 结构体放在 $defs 中，没有 omitempty 的标量字段视为必填
+func ConfigSchema() map[string]any {
+	defs := map[string]any{}
+	root := structSchema(reflect.TypeOf(Config{}), defs)
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["$id"] = SchemaID
+	root["title"] = "combinator gateway config"
+	root["$defs"] = defs
+	return root
+}
+
+func typeSchema(t reflect.Type, defs map[string]any) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem(), defs)
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem(), defs)}
+	case reflect.Struct:
+		if _, ok := defs[t.Name()]; !ok {
+			defs[t.Name()] = nil // 占位，防止递归类型无限展开
+			def := structSchema(t, defs)
+			if shorthandTypes[t] {
+				def = map[string]any{"oneOf": []any{
+					map[string]any{"type": "string", "description": "instance id, uses the default URL"},
+					def,
+				}}
+			}
+			defs[t.Name()] = def
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	default:
+		// any，例如实例的 metadata
+		return map[string]any{}
+	}
+}
+
+func structSchema(t reflect.Type, defs map[string]any) map[string]any {
+	props := map[string]any{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = typeSchema(f.Type, defs)
+
+		omit := strings.Contains(opts, "omitempty") || strings.Contains(opts, "omitzero")
+		switch f.Type.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+			// 可以为空的字段解码时都有零值，不要求出现
+		default:
+			if !omit {
+				required = append(required, name)
+			}
+		}
+	}
+	s := map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
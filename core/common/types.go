@@ -1,22 +1,47 @@
 package combinator
 
+import (
+	"context"
+	"time"
+)
+
 type Service interface {
 	Start() error
 	Close() error
 	Type() string
+	// Ping 健康探测，实例可用时返回 nil
+	Ping(ctx context.Context) error
 }
 
 type RDB interface {
 	Service
-	Query(stmt string, args ...any) (data []byte, err error)
-	Exec(stmt string, args ...any) error
-	Batch(stmt []string, args [][]any) error
+	Query(ctx context.Context, stmt string, args ...any) (data []byte, err error)
+	Exec(ctx context.Context, stmt string, args ...any) error
+	Batch(ctx context.Context, stmt []string, args [][]any) error
 }
 
 type KV interface {
 	Service
-	Get(key string) ([]byte, error)
-	Set(key string, value []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte) error
+}
+
+// SizeLimited 配置了 maxValueSize 的 KV / S3 实例，网关据此在读取请求体时限制大小
+type SizeLimited interface {
+	MaxValueSize() int64
+}
+
+// MaxValueSizeOf 返回实例的 maxValueSize，0 表示不限制
+func MaxValueSizeOf(s Service) int64 {
+	if l, ok := s.(SizeLimited); ok {
+		return l.MaxValueSize()
+	}
+	return 0
+}
+
+// ExpiringKV 可以为单个 key 指定过期时间的 KV（memory、redis）
+type ExpiringKV interface {
+	SetTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error
 }
 
 type Queue interface {
@@ -25,10 +50,10 @@ type Queue interface {
 
 type S3 interface {
 	Service
-	Get(key string) ([]byte, error)
-	Put(key string, value []byte) error
-	List(prefix string) ([]string, error)
-	Delete(key string) error
-	GeneratePresignedUploadURL(key string) (string, error)
-	GeneratePresignedDownloadURL(key string) (string, error)
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, value []byte) error
+	List(ctx context.Context, prefix string) ([]string, error)
+	Delete(ctx context.Context, key string) error
+	GeneratePresignedUploadURL(ctx context.Context, key string) (string, error)
+	GeneratePresignedDownloadURL(ctx context.Context, key string) (string, error)
 }
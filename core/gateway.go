@@ -1,36 +1,84 @@
 package combinator
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	auth "jabberwocky238/combinator/core/auth"
 	common "jabberwocky238/combinator/core/common"
+	history "jabberwocky238/combinator/core/history"
 	kvModule "jabberwocky238/combinator/core/kv"
+	ratelimit "jabberwocky238/combinator/core/ratelimit"
 	rdbModule "jabberwocky238/combinator/core/rdb"
 	s3Module "jabberwocky238/combinator/core/s3"
 )
 
 type Gateway struct {
 	g          *gin.Engine
+	srv        *http.Server
 	rdbGateway *rdbModule.RDBGateway
 	kvGateway  *kvModule.KVGateway
 	s3Gateway  *s3Module.S3Gateway
+	conf       *common.Config // 原始配置，保留 ${...} 引用，用于写回文件、修订历史与管理接口
+	resolved   *common.Config // 展开 ${...} 之后的配置，实例与认证使用
+	resolveErr error
+	auth       atomic.Pointer[auth.Authenticator]
+	tls        *certReloader
+	metrics    *gatewayMetrics
+	health     *healthChecker
+	limiter    atomic.Pointer[ratelimit.Limiter]
+	limitStore *ratelimit.MemoryStore
+	breaker    *common.BreakerPolicy // 所有实例熔断器共享的参数
+	timeouts   atomic.Pointer[timeoutPolicy]
+	cors       atomic.Pointer[corsPolicy]
+	corsDef    *common.CORSConfig // 配置中没有 cors 时使用的策略，nil 表示不处理跨域
+	reloadMu   sync.Mutex
+
+	configFile   string // 非空时 UpdateConfig / Rollback 会把修改写回该文件
+	configFormat common.ConfigFormat
+
+	history *history.Store
 }
 
+// NewGateway cors 为 true 时，配置中没有 cors 段也允许任意来源（用于 dev）
 func NewGateway(confIn *common.Config, cors bool) *Gateway {
 	conf := confIn
 	r := gin.New()
-	r.Use(gin.LoggerWithConfig(gin.LoggerConfig{
-		SkipPaths: []string{"/health"},
-	}))
+	// 不信任任何代理头，ClientIP 使用连接的对端地址，防止伪造 X-Forwarded-For 绕过按 IP 限流
+	r.SetTrustedProxies(nil)
+	gw := &Gateway{
+		g:          r,
+		srv:        &http.Server{Handler: r},
+		conf:       conf,
+		limitStore: ratelimit.NewMemoryStore(),
+		breaker:    common.NewBreakerPolicy(),
+	}
+	// 展开失败时 Start 返回错误，这里先用原始配置创建各服务
+	gw.resolved, gw.resolveErr = common.ExpandConfig(conf)
+	resolved := gw.resolved
+	if gw.resolveErr != nil {
+		resolved = conf
+	}
+	// 访问日志在最外层，panic 恢复后的 500 也会被记录
+	r.Use(gw.middlewareLog())
 	r.Use(gin.Recovery())
 	if cors {
-		openGatewayCors(r)
+		gw.corsDef = devCORSConfig
 	}
+	r.Use(gw.middlewareCORS())
+	r.Use(gw.middlewareTrace())
+
 	r.GET("/", func(c *gin.Context) {
 		// text and timestamp
 		timestamp := time.Now().Format(time.RFC3339)
@@ -43,31 +91,61 @@ func NewGateway(confIn *common.Config, cors bool) *Gateway {
 			"service": "combinator",
 		})
 	})
+	// Readiness: 所有实例的健康探测都通过才返回 200
+	r.GET("/health/ready", gw.handleReady)
 
-	return &Gateway{
-		g:          r,
-		rdbGateway: rdbModule.NewGateway(r.Group("/rdb"), conf.Rdb),
-		kvGateway:  kvModule.NewGateway(r.Group("/kv"), conf.Kv),
-		s3Gateway:  s3Module.NewGateway(r.Group("/s3"), conf.S3),
-	}
+	gw.metrics = newGatewayMetrics(gw)
+	gw.health = newHealthChecker(gw)
+	// 指标、认证与限流中间件挂在路由组上，先于各服务自身的中间件执行
+	gw.rdbGateway = rdbModule.NewGateway(r.Group("/rdb", gw.groupMiddlewares("rdb")...), resolved.Rdb)
+	gw.kvGateway = kvModule.NewGateway(r.Group("/kv", gw.groupMiddlewares("kv")...), resolved.Kv)
+	gw.s3Gateway = s3Module.NewGateway(r.Group("/s3", gw.groupMiddlewares("s3")...), resolved.S3)
+	gw.rdbGateway.SetBreaker(gw.breaker)
+	gw.kvGateway.SetBreaker(gw.breaker)
+	gw.s3Gateway.SetBreaker(gw.breaker)
+	return gw
 }
 
-func openGatewayCors(r *gin.Engine) {
-	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Combinator-RDB-ID, X-Combinator-KV-ID, X-Combinator-KV-Key, X-Combinator-S3-ID")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT")
-	})
-
-	// options
-	r.OPTIONS("/*cors", func(c *gin.Context) {
-		c.AbortWithStatus(204)
-	})
+func (gw *Gateway) groupMiddlewares(kind string) []gin.HandlerFunc {
+	return []gin.HandlerFunc{
+		middlewareLogTarget(kind),
+		gw.middlewareMetrics(kind),
+		gw.middlewareAuth(kind),
+		gw.middlewareRateLimit(kind),
+		gw.middlewareTimeout(kind),
+	}
 }
 
 func (gw *Gateway) Start(addr string) error {
-	err := gw.rdbGateway.Start()
+	if gw.resolveErr != nil {
+		return gw.resolveErr
+	}
+	err := gw.reloadAuth(gw.resolved.Auth)
+	if err != nil {
+		return err
+	}
+
+	breaker, err := common.NewBreakerSettings(gw.resolved.CircuitBreaker)
+	if err != nil {
+		return err
+	}
+	gw.breaker.Store(breaker)
+	timeouts, err := newTimeoutPolicy(gw.resolved.Timeouts)
+	if err != nil {
+		return err
+	}
+	gw.timeouts.Store(timeouts)
+	cors, err := newCORSPolicy(gw.resolved.CORS, gw.corsDef)
+	if err != nil {
+		return err
+	}
+	gw.cors.Store(cors)
+	// 选项错误不会因重试而恢复，启动前直接报错
+	if err := validateInstances(gw.conf, gw.resolved); err != nil {
+		return err
+	}
+
+	err = gw.rdbGateway.Start()
 	if err != nil {
 		return err
 	}
@@ -82,34 +160,272 @@ func (gw *Gateway) Start(addr string) error {
 		return err
 	}
 
-	return gw.g.Run(addr)
+	limiter, err := gw.newRateLimiter(gw.resolved)
+	if err != nil {
+		return err
+	}
+	gw.limiter.Store(limiter)
+	gw.recordStart()
+	gw.health.start()
+
+	gw.srv.Addr = addr
+	if gw.tls == nil {
+		err = gw.srv.ListenAndServe()
+	} else {
+		gw.srv.TLSConfig = gw.tls.tlsConfig()
+		// 证书由 TLSConfig 提供，这里不需要文件路径
+		err = gw.srv.ListenAndServeTLS("", "")
+	}
+	// Shutdown 触发的关闭不算错误
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
 }
 
-// Reload 重新加载配置
-func (gw *Gateway) Reload(confIn *common.Config) error {
-	conf := confIn
+// Shutdown 停止接受新请求，等待进行中的请求完成（最多到 ctx 截止），
+// 然后关闭所有 RDB / KV / S3 实例，返回所有失败
+func (gw *Gateway) Shutdown(ctx context.Context) error {
+	var errs []error
+	gw.health.stop()
+	if err := gw.srv.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("http server: %w", err))
+	}
 
-	// 重新加载 RDB Gateway
-	if err := gw.rdbGateway.Reload(conf.Rdb); err != nil {
-		return err
+	// 即使等待超时也要关闭后端，例如 RocksDB 需要正常 Close
+	// 持有 reloadMu，避免进行中的重载在关闭后重新提交实例
+	gw.reloadMu.Lock()
+	defer gw.reloadMu.Unlock()
+	errs = append(errs,
+		gw.rdbGateway.Close(),
+		gw.kvGateway.Close(),
+		gw.s3Gateway.Close(),
+	)
+	return errors.Join(errs...)
+}
+
+// Reload 两阶段重新加载配置：先构建并启动所有新增或变化的实例，
+// 全部成功后才替换实例表并关闭旧实例；任一失败则丢弃新实例，继续使用旧配置
+// source 记录在修订历史中，例如 file、api
+func (gw *Gateway) Reload(confIn *common.Config, source string) (*common.ReloadResult, error) {
+	gw.reloadMu.Lock()
+	defer gw.reloadMu.Unlock()
+
+	return gw.apply(confIn, source, false)
+}
+
+// DryRun 对 confIn 做与 config validate 相同的静态检查，并计算每个实例在重载时的变化
+// （unchanged / added / replaced / removed），检查失败的实例记为 failed；
+// 不启动或关闭任何实例，也不记录修订。配置无法应用时返回的 error 包含所有问题
+func (gw *Gateway) DryRun(confIn *common.Config) (*common.ReloadResult, error) {
+	report := ValidateConfig(context.Background(), confIn, ValidateOptions{})
+	invalid := make(map[string]string)
+	for _, inst := range report.Instances {
+		if inst.Status != CheckOK {
+			invalid[inst.Kind+"/"+inst.ID] = inst.Error
+		}
 	}
 
-	// 重新加载 KV Gateway
-	if err := gw.kvGateway.Reload(conf.Kv); err != nil {
-		return err
+	// 实例表中保存的是展开后的配置；无法展开时错误已在报告中
+	conf, err := common.ExpandConfig(confIn)
+	if err != nil {
+		conf = confIn
 	}
 
-	// 重新加载 S3 Gateway
-	if err := gw.s3Gateway.Reload(conf.S3); err != nil {
-		return err
+	gw.reloadMu.Lock()
+	result := &common.ReloadResult{DryRun: true, Instances: []common.InstanceResult{}}
+	result.Instances = append(result.Instances, gw.rdbGateway.Diff(conf.Rdb)...)
+	result.Instances = append(result.Instances, gw.kvGateway.Diff(conf.Kv)...)
+	result.Instances = append(result.Instances, gw.s3Gateway.Diff(conf.S3)...)
+	gw.reloadMu.Unlock()
+
+	for i, inst := range result.Instances {
+		if msg, ok := invalid[inst.Kind+"/"+inst.ID]; ok && inst.Action != common.ReloadRemoved {
+			result.Instances[i].Action = common.ReloadFailed
+			result.Instances[i].Error = msg
+		}
+	}
+	if err := report.Err(); err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+	return result, nil
+}
+
+// apply 提交配置并记录修订，调用方需持有 reloadMu
+// persist 为 true 且设置了配置文件时，成功后写回文件
+func (gw *Gateway) apply(conf *common.Config, source string, persist bool) (*common.ReloadResult, error) {
+	prev := gw.conf
+	result, err := gw.reload(conf)
+	gw.metrics.observeReload(err)
+	if err == nil && persist && gw.configFile != "" {
+		if werr := common.WriteConfigFileAs(gw.configFile, conf, gw.configFormat); werr != nil {
+			err = fmt.Errorf("config applied but not persisted: %w", werr)
+		}
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	gw.recordRevision(source, prev, conf, result)
+	return result, err
+}
+
+func (gw *Gateway) reload(confIn *common.Config) (*common.ReloadResult, error) {
+	result := &common.ReloadResult{Instances: []common.InstanceResult{}}
+	// 每次重载都重新读取环境变量与密钥文件，密钥轮换后实例会被替换
+	conf, err := common.ExpandConfig(confIn)
+	if err != nil {
+		return result, invalidConfig(err)
+	}
+
+	// 第一阶段：构建认证与限流（纯内存，无需回滚）
+	authenticator, err := auth.New(conf.Auth)
+	if err != nil {
+		return result, invalidConfig(err)
+	}
+	limiter, err := gw.newRateLimiter(conf)
+	if err != nil {
+		return result, invalidConfig(err)
+	}
+	breaker, err := common.NewBreakerSettings(conf.CircuitBreaker)
+	if err != nil {
+		return result, invalidConfig(err)
+	}
+	timeouts, err := newTimeoutPolicy(conf.Timeouts)
+	if err != nil {
+		return result, invalidConfig(err)
+	}
+	cors, err := newCORSPolicy(conf.CORS, gw.corsDef)
+	if err != nil {
+		return result, invalidConfig(err)
+	}
+	if err := validateInstances(confIn, conf); err != nil {
+		return result, invalidConfig(err)
 	}
 
-	return nil
+	// 第一阶段：启动新增或变化的实例
+	rdbPlan, rdbErr := gw.rdbGateway.Prepare(conf.Rdb)
+	kvPlan, kvErr := gw.kvGateway.Prepare(conf.Kv)
+	s3Plan, s3Err := gw.s3Gateway.Prepare(conf.S3)
+	result.Instances = append(result.Instances, rdbPlan.Results...)
+	result.Instances = append(result.Instances, kvPlan.Results...)
+	result.Instances = append(result.Instances, s3Plan.Results...)
+
+	if err := errors.Join(rdbErr, kvErr, s3Err); err != nil {
+		rdbPlan.Abort()
+		kvPlan.Abort()
+		s3Plan.Abort()
+		// 返回给调用方的错误中不能出现 ${...} 展开后的值
+		err = hideResolvedResults(result, confIn)
+		common.Logger.Errorf("Reload aborted, keeping previous configuration: %v", err)
+		return result, err
+	}
+
+	// 第二阶段：替换并关闭旧实例
+	// 提交只会因并发写入失败，reloadMu 下不应发生
+	if err := errors.Join(
+		gw.rdbGateway.Commit(rdbPlan),
+		gw.kvGateway.Commit(kvPlan),
+		gw.s3Gateway.Commit(s3Plan),
+	); err != nil {
+		return result, err
+	}
+	gw.auth.Store(authenticator)
+	gw.limiter.Store(limiter)
+	gw.breaker.Store(breaker)
+	gw.timeouts.Store(timeouts)
+	gw.cors.Store(cors)
+
+	gw.conf = confIn
+	gw.resolved = conf
+	result.Applied = true
+	gw.health.probeSoon()
+	return result, nil
+}
+
+// configError 配置本身无效（展开或校验失败），与实例启动、写回文件失败区分开，
+// API 对前者返回 400，对后者返回 500
+type configError struct{ err error }
+
+func (e *configError) Error() string { return e.err.Error() }
+func (e *configError) Unwrap() error { return e.err }
+
+func invalidConfig(err error) error {
+	return &configError{err}
+}
+
+// validateInstances 校验实例 ID 是否重复以及所有实例的 URL 与 Metadata 选项，不启动实例；
+// conf 为展开后的配置，错误信息按 raw 中的 URL 隐藏展开值
+func validateInstances(raw, conf *common.Config) error {
+	errs := []error{duplicateIDs(conf)}
+	for i, c := range conf.Rdb {
+		if _, err := rdbModule.Check(c); err != nil {
+			errs = append(errs, fmt.Errorf("RDB %s: %w", c.ID, common.HideResolved(err, raw.Rdb[i].URL)))
+		}
+	}
+	for i, c := range conf.Kv {
+		if _, err := kvModule.Check(c); err != nil {
+			errs = append(errs, fmt.Errorf("KV %s: %w", c.ID, common.HideResolved(err, raw.Kv[i].URL)))
+		}
+	}
+	for i, c := range conf.S3 {
+		if _, err := s3Module.Check(c); err != nil {
+			errs = append(errs, fmt.Errorf("S3 %s: %w", c.ID, common.HideResolved(err, raw.S3[i].URL)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// hideResolvedResults 隐藏启动失败的实例错误中 ${...} 展开后的值（例如连接错误中的主机名），
+// 返回按实例重新组合的错误
+func hideResolvedResults(result *common.ReloadResult, raw *common.Config) error {
+	urls := make(map[string]string)
+	for _, c := range raw.Rdb {
+		urls["rdb/"+c.ID] = c.URL
+	}
+	for _, c := range raw.Kv {
+		urls["kv/"+c.ID] = c.URL
+	}
+	for _, c := range raw.S3 {
+		urls["s3/"+c.ID] = c.URL
+	}
+	var errs []error
+	for i, inst := range result.Instances {
+		if inst.Error == "" {
+			continue
+		}
+		err := common.HideResolved(errors.New(inst.Error), urls[inst.Kind+"/"+inst.ID])
+		result.Instances[i].Error = err.Error()
+		errs = append(errs, fmt.Errorf("%s %s: %w", strings.ToUpper(inst.Kind), inst.ID, err))
+	}
+	return errors.Join(errs...)
+}
+
+// UpdateConfig 在当前配置的副本上执行 mutate，再按 Reload 的方式提交；
+// 成功后如果设置了配置文件则写回文件
+func (gw *Gateway) UpdateConfig(source string, mutate func(conf *common.Config) error) (*common.ReloadResult, error) {
+	gw.reloadMu.Lock()
+	defer gw.reloadMu.Unlock()
+
+	conf, err := gw.conf.Clone()
+	if err != nil {
+		return &common.ReloadResult{Instances: []common.InstanceResult{}, Error: err.Error()}, err
+	}
+	if err := mutate(conf); err != nil {
+		return &common.ReloadResult{Instances: []common.InstanceResult{}, Error: err.Error()}, invalidConfig(err)
+	}
+	return gw.apply(conf, source, true)
+}
+
+// SetConfigFile 设置后 UpdateConfig 与 Rollback 成功时会按 format 把配置写回该文件
+func (gw *Gateway) SetConfigFile(path string, format common.ConfigFormat) {
+	gw.configFile = path
+	gw.configFormat = format
 }
 
 // API 监听
-func (gw *Gateway) SetupReloadAPI(reloadChan chan<- *common.Config) {
-	gw.g.POST("/reload", func(c *gin.Context) {
+func (gw *Gateway) SetupReloadAPI(reloadChan chan<- common.ReloadRequest) {
+	gw.g.POST("/reload", middlewareLogTarget("admin"), gw.middlewareMetrics("admin"), gw.middlewareAuth("admin"), func(c *gin.Context) {
 		if c.Request.Method != http.MethodPost {
 			c.JSON(405, gin.H{"error": "Method not allowed"})
 			return
@@ -126,9 +442,45 @@ func (gw *Gateway) SetupReloadAPI(reloadChan chan<- *common.Config) {
 			c.JSON(400, gin.H{"error": "Invalid JSON"})
 			return
 		}
+		dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+
+		gw.reloadMu.Lock()
+		err = common.CheckFileRefs(&config, gw.conf)
+		gw.reloadMu.Unlock()
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 先做静态检查，无法应用的配置不进入重载循环
+		plan, err := gw.DryRun(&config)
+		if err != nil || dryRun {
+			status := http.StatusOK
+			if err != nil {
+				status = http.StatusBadRequest
+			}
+			plan.DryRun = dryRun
+			c.JSON(status, plan)
+			return
+		}
 
-		common.Logger.Infof("Received reload request via API...")
-		reloadChan <- &config
-		c.String(200, "Config Reloaded")
+		common.Log(c.Request.Context()).Infof("Received reload request via API...")
+		done := make(chan *common.ReloadResult, 1)
+		ctx := c.Request.Context()
+		select {
+		case reloadChan <- common.ReloadRequest{Config: &config, Source: "api", Result: done}:
+		case <-ctx.Done():
+			return
+		}
+		// 等待重载循环返回结果；客户端断开时重载仍会完成
+		select {
+		case result := <-done:
+			status := http.StatusOK
+			if !result.Applied {
+				status = http.StatusInternalServerError
+			}
+			c.JSON(status, result)
+		case <-ctx.Done():
+		}
 	})
 }
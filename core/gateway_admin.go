@@ -0,0 +1,192 @@
+package combinator
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+// InstanceRequest PUT /admin/{kind}/:id 的请求体
+type InstanceRequest struct {
+	ID       string `json:"id,omitempty"`
+	URL      string `json:"url"`
+	Metadata any    `json:"metadata,omitempty"`
+}
+
+// InstanceInfo GET /admin/{kind}/:id 的响应
+type InstanceInfo struct {
+	Kind     string `json:"kind"`
+	ID       string `json:"id"`
+	URL      string `json:"url"`
+	Metadata any    `json:"metadata,omitempty"`
+	Loaded   bool   `json:"loaded"`
+	Error    string `json:"error,omitempty"` // 启动失败的原因，实例正在后台重试
+}
+
+var errInstanceNotFound = errors.New("instance not found")
+
+// SetupAdminAPI 注册单实例管理接口 /admin/{rdb,kv,s3}/:id (GET / PUT / DELETE)
+// 配合 SetConfigFile 使用时，成功的修改会写回配置文件，重启后仍然生效
+func (gw *Gateway) SetupAdminAPI() {
+	admin := gw.g.Group("/admin", middlewareLogTarget("admin"), gw.middlewareMetrics("admin"), gw.middlewareAuth("admin"))
+	for _, kind := range []string{"rdb", "kv", "s3"} {
+		admin.GET("/"+kind+"/:id", gw.handleAdminGet(kind))
+		admin.PUT("/"+kind+"/:id", gw.handleAdminPut(kind))
+		admin.DELETE("/"+kind+"/:id", gw.handleAdminDelete(kind))
+	}
+}
+
+func (gw *Gateway) handleAdminGet(kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		gw.reloadMu.Lock()
+		info, ok := findInstance(gw.conf, kind, id)
+		gw.reloadMu.Unlock()
+
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": errInstanceNotFound.Error()})
+			return
+		}
+		if info.URL == "" {
+			// 只写 ID 的实例
+			info.URL = common.DefaultURL(kind, id)
+		}
+		info.URL = common.RedactURL(info.URL)
+		info.Loaded = gw.hasInstance(kind, id)
+		for _, u := range gw.unavailable(kind) {
+			if u.ID == id {
+				info.Loaded = false
+				info.Error = u.Cause
+			}
+		}
+		c.JSON(http.StatusOK, info)
+	}
+}
+
+func (gw *Gateway) handleAdminPut(kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var req InstanceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+		if req.ID != "" && req.ID != id {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "id in body does not match path"})
+			return
+		}
+		if req.URL == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "missing url"})
+			return
+		}
+
+		common.Logger.Infof("Admin API: put %s %s", kind, id)
+		result, err := gw.UpdateConfig("admin", func(conf *common.Config) error {
+			upsertInstance(conf, kind, id, req.URL, req.Metadata)
+			// UpdateConfig 持有 reloadMu，gw.conf 为当前配置
+			return common.CheckFileRefs(conf, gw.conf)
+		})
+		gw.respondUpdate(c, result, err)
+	}
+}
+
+func (gw *Gateway) handleAdminDelete(kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		common.Logger.Infof("Admin API: delete %s %s", kind, id)
+		result, err := gw.UpdateConfig("admin", func(conf *common.Config) error {
+			if !removeInstance(conf, kind, id) {
+				return errInstanceNotFound
+			}
+			return nil
+		})
+		gw.respondUpdate(c, result, err)
+	}
+}
+
+// respondUpdate 无效的修改返回 400，实例启动或写回配置文件失败返回 500
+func (gw *Gateway) respondUpdate(c *gin.Context, result *common.ReloadResult, err error) {
+	var invalid *configError
+	switch {
+	case errors.Is(err, errInstanceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusBadRequest, result)
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, result)
+	default:
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// findInstance 在配置中查找实例
+func findInstance(conf *common.Config, kind, id string) (InstanceInfo, bool) {
+	info := InstanceInfo{Kind: kind, ID: id}
+	switch kind {
+	case "rdb":
+		if i := slices.IndexFunc(conf.Rdb, func(c common.RDBConfig) bool { return c.ID == id }); i >= 0 {
+			info.URL, info.Metadata = conf.Rdb[i].URL, conf.Rdb[i].Metadata
+			return info, true
+		}
+	case "kv":
+		if i := slices.IndexFunc(conf.Kv, func(c common.KVConfig) bool { return c.ID == id }); i >= 0 {
+			info.URL, info.Metadata = conf.Kv[i].URL, conf.Kv[i].Metadata
+			return info, true
+		}
+	case "s3":
+		if i := slices.IndexFunc(conf.S3, func(c common.S3Config) bool { return c.ID == id }); i >= 0 {
+			info.URL, info.Metadata = conf.S3[i].URL, conf.S3[i].Metadata
+			return info, true
+		}
+	}
+	return info, false
+}
+
+// upsertInstance 替换同 ID 的实例，不存在时追加到末尾
+func upsertInstance(conf *common.Config, kind, id, url string, metadata any) {
+	switch kind {
+	case "rdb":
+		conf.Rdb = upsert(conf.Rdb, common.RDBConfig{ID: id, URL: url, Metadata: metadata})
+	case "kv":
+		conf.Kv = upsert(conf.Kv, common.KVConfig{ID: id, URL: url, Metadata: metadata})
+	case "s3":
+		conf.S3 = upsert(conf.S3, common.S3Config{ID: id, URL: url, Metadata: metadata})
+	}
+}
+
+// removeInstance 删除实例，返回是否存在
+func removeInstance(conf *common.Config, kind, id string) bool {
+	var removed bool
+	switch kind {
+	case "rdb":
+		conf.Rdb, removed = remove(conf.Rdb, id)
+	case "kv":
+		conf.Kv, removed = remove(conf.Kv, id)
+	case "s3":
+		conf.S3, removed = remove(conf.S3, id)
+	}
+	return removed
+}
+
+func upsert[C common.InstanceConfig[C]](list []C, item C) []C {
+	for i, c := range list {
+		if c.InstanceID() == item.InstanceID() {
+			list[i] = item
+			return list
+		}
+	}
+	return append(list, item)
+}
+
+func remove[C common.InstanceConfig[C]](list []C, id string) ([]C, bool) {
+	n := len(list)
+	list = slices.DeleteFunc(list, func(c C) bool { return c.InstanceID() == id })
+	return list, len(list) != n
+}
@@ -0,0 +1,156 @@
+package combinator
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+var defaultCORSMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+
+var defaultCORSHeaders = []string{
+	"Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization",
+	"Accept", "Origin", "Cache-Control", "X-Requested-With", "traceparent", "tracestate",
+	RequestIDHeader, "X-Combinator-RDB-ID", "X-Combinator-KV-ID", "X-Combinator-KV-Key", "X-Combinator-S3-ID",
+}
+
+// 浏览器默认读不到这些响应头
+var corsExposeHeaders = strings.Join([]string{RequestIDHeader, "traceparent"}, ", ")
+
+// devCORSConfig dev 模式未配置 cors 时使用：允许任意来源，不带凭证
+var devCORSConfig = &common.CORSConfig{AllowOrigins: []string{"*"}}
+
+// originPattern "https://*.example.com" 拆成前后缀，"*" 至少匹配一个字符
+type originPattern struct {
+	prefix, suffix string
+}
+
+func (p originPattern) match(origin string) bool {
+	return len(origin) > len(p.prefix)+len(p.suffix) &&
+		strings.HasPrefix(origin, p.prefix) && strings.HasSuffix(origin, p.suffix)
+}
+
+// corsPolicy 由 CORSConfig 编译而来，nil 表示不处理跨域
+type corsPolicy struct {
+	any         bool
+	exact       map[string]bool
+	patterns    []originPattern
+	methods     string
+	headers     string
+	credentials bool
+	maxAge      string
+}
+
+// newCORSPolicy conf 为 nil 时使用 fallback，二者都为 nil 时返回 nil
+func newCORSPolicy(conf, fallback *common.CORSConfig) (*corsPolicy, error) {
+	if conf == nil {
+		conf = fallback
+	}
+	if conf == nil {
+		return nil, nil
+	}
+	if len(conf.AllowOrigins) == 0 {
+		return nil, fmt.Errorf("cors: allowOrigins must not be empty")
+	}
+	if conf.MaxAge < 0 {
+		return nil, fmt.Errorf("cors: maxAge must not be negative")
+	}
+	p := &corsPolicy{exact: make(map[string]bool), credentials: conf.AllowCredentials}
+	for _, origin := range conf.AllowOrigins {
+		switch n := strings.Count(origin, "*"); {
+		case origin == "*":
+			p.any = true
+		case n == 0:
+			p.exact[strings.TrimSuffix(origin, "/")] = true
+		case n == 1:
+			prefix, suffix, _ := strings.Cut(origin, "*")
+			p.patterns = append(p.patterns, originPattern{prefix, suffix})
+		default:
+			return nil, fmt.Errorf("cors: invalid origin pattern %q: at most one * is allowed", origin)
+		}
+	}
+	if p.any && p.credentials {
+		return nil, fmt.Errorf(`cors: allowCredentials cannot be used with origin "*", list the allowed origins instead`)
+	}
+
+	methods := defaultCORSMethods
+	if len(conf.AllowMethods) > 0 {
+		methods = make([]string, len(conf.AllowMethods))
+		for i, m := range conf.AllowMethods {
+			methods[i] = strings.ToUpper(m)
+		}
+	}
+	p.methods = strings.Join(methods, ", ")
+	headers := defaultCORSHeaders
+	if len(conf.AllowHeaders) > 0 {
+		headers = conf.AllowHeaders
+	}
+	p.headers = strings.Join(headers, ", ")
+	if conf.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(conf.MaxAge)
+	}
+	return p, nil
+}
+
+func (p *corsPolicy) allows(origin string) bool {
+	if p.any || p.exact[origin] {
+		return true
+	}
+	for _, pattern := range p.patterns {
+		if pattern.match(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// middlewareCORS 按当前策略输出 CORS 头并直接响应预检请求，策略随配置重载更新
+func (gw *Gateway) middlewareCORS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := gw.cors.Load()
+		origin := c.GetHeader("Origin")
+		if p == nil || origin == "" {
+			c.Next()
+			return
+		}
+
+		h := c.Writer.Header()
+		h.Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if !p.allows(origin) {
+			// 不带 CORS 头，由浏览器拒绝
+			if preflight {
+				c.AbortWithStatus(http.StatusNoContent)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if p.any && !p.credentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if p.credentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			h.Set("Access-Control-Expose-Headers", corsExposeHeaders)
+			c.Next()
+			return
+		}
+
+		h.Set("Access-Control-Allow-Methods", p.methods)
+		h.Set("Access-Control-Allow-Headers", p.headers)
+		if p.maxAge != "" {
+			h.Set("Access-Control-Max-Age", p.maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,289 @@
+package combinator
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+const (
+	HealthUp          = "up"
+	HealthDown        = "down"
+	HealthUnknown     = "unknown"
+	HealthUnavailable = "unavailable" // 启动失败，正在后台重试
+
+	defaultHealthInterval = 15 * time.Second
+	defaultHealthTimeout  = 3 * time.Second
+
+	// readyCacheTTL 关闭后台探测时，按需探测的结果在这段时间内复用，
+	// 防止未认证的 /health/ready 请求放大为对每个后端的 Ping
+	readyCacheTTL = 2 * time.Second
+)
+
+// InstanceHealth 单个实例最近一次探测的结果
+type InstanceHealth struct {
+	Kind      string    `json:"kind"`
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Status    string    `json:"status"`
+	LatencyMs float64   `json:"latencyMs"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt,omitzero"`
+}
+
+// ReadinessResult /health/ready 的响应，调用方无权查看实例时只有 Status
+type ReadinessResult struct {
+	Status    string           `json:"status"`
+	Instances []InstanceHealth `json:"instances"`
+}
+
+// healthChecker 定期对所有实例执行 Ping，结果供 /health/ready 与 /monitor 的 service.list 使用；
+// 关闭后台探测时由 /health/ready 按需探测
+type healthChecker struct {
+	gw       *Gateway
+	interval time.Duration // 0 表示不做后台探测，只在 /health/ready 时探测
+	timeout  time.Duration
+
+	mu      sync.RWMutex
+	results map[string]InstanceHealth // kind/id -> result
+	lastRun time.Time
+	probeMu sync.Mutex // 同时到达的按需探测只执行一轮
+
+	trigger chan struct{}
+	cancel  context.CancelFunc
+}
+
+type probeTarget struct {
+	kind string
+	id   string
+	svc  common.Service
+}
+
+func newHealthChecker(gw *Gateway) *healthChecker {
+	return &healthChecker{
+		gw:       gw,
+		interval: defaultHealthInterval,
+		timeout:  defaultHealthTimeout,
+		results:  make(map[string]InstanceHealth),
+		trigger:  make(chan struct{}, 1),
+	}
+}
+
+// SetHealthCheck 设置后台探测间隔与单次探测超时，需在 Start 之前调用
+// interval 为 0 时关闭后台探测
+func (gw *Gateway) SetHealthCheck(interval, timeout time.Duration) {
+	gw.health.interval = interval
+	if timeout > 0 {
+		gw.health.timeout = timeout
+	}
+}
+
+func (h *healthChecker) start() {
+	if h.interval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+	go h.run(ctx)
+}
+
+func (h *healthChecker) stop() {
+	if h.cancel != nil {
+		h.cancel()
+	}
+}
+
+func (h *healthChecker) run(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	h.probeAll(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-h.trigger:
+		}
+		h.probeAll(ctx)
+	}
+}
+
+// probeSoon 请求尽快执行一次探测，例如重载之后
+func (h *healthChecker) probeSoon() {
+	select {
+	case h.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (h *healthChecker) targets() []probeTarget {
+	var targets []probeTarget
+	h.gw.rdbGateway.Range(func(id string, rdb common.RDB) bool {
+		targets = append(targets, probeTarget{"rdb", id, rdb})
+		return true
+	})
+	h.gw.kvGateway.Range(func(id string, kv common.KV) bool {
+		targets = append(targets, probeTarget{"kv", id, kv})
+		return true
+	})
+	h.gw.s3Gateway.Range(func(id string, s3 common.S3) bool {
+		targets = append(targets, probeTarget{"s3", id, s3})
+		return true
+	})
+	return targets
+}
+
+// probeAll 并发探测所有实例，结果替换上一轮（已删除的实例随之消失）
+func (h *healthChecker) probeAll(ctx context.Context) []InstanceHealth {
+	targets := h.targets()
+	out := make([]InstanceHealth, len(targets))
+
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out[i] = h.probe(ctx, t)
+		}()
+	}
+	wg.Wait()
+
+	results := make(map[string]InstanceHealth, len(out))
+	for _, r := range out {
+		prev, ok := h.get(r.Kind, r.ID)
+		if r.Status == HealthDown && (!ok || prev.Status != HealthDown) {
+			common.InstanceLog(r.Kind, r.ID).Warnf("Health check failed for %s %s: %s", r.Kind, r.ID, r.Error)
+		} else if r.Status == HealthUp && ok && prev.Status == HealthDown {
+			common.InstanceLog(r.Kind, r.ID).Infof("Health check recovered for %s %s", r.Kind, r.ID)
+		}
+		results[r.Kind+"/"+r.ID] = r
+	}
+
+	h.mu.Lock()
+	h.results = results
+	h.lastRun = time.Now()
+	h.mu.Unlock()
+	return out
+}
+
+func (h *healthChecker) probe(ctx context.Context, t probeTarget) InstanceHealth {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := t.svc.Ping(ctx)
+	res := InstanceHealth{
+		Kind:      t.kind,
+		ID:        t.id,
+		Type:      t.svc.Type(),
+		Status:    HealthUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: time.Now(),
+	}
+	if err != nil {
+		res.Status = HealthDown
+		res.Error = err.Error()
+	}
+	return res
+}
+
+func (h *healthChecker) get(kind, id string) (InstanceHealth, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	r, ok := h.results[kind+"/"+id]
+	return r, ok
+}
+
+// status 返回实例最近一次探测结果，尚未探测过时为 unknown
+func (h *healthChecker) status(kind, id, typ string) InstanceHealth {
+	if r, ok := h.get(kind, id); ok {
+		return r
+	}
+	return InstanceHealth{Kind: kind, ID: id, Type: typ, Status: HealthUnknown}
+}
+
+// fresh 最近一轮探测结果是否仍可直接使用
+func (h *healthChecker) fresh() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.interval > 0 {
+		return time.Since(h.lastRun) < 2*h.interval
+	}
+	return time.Since(h.lastRun) < readyCacheTTL
+}
+
+// readiness 探测结果足够新时直接使用，否则立即探测一轮
+func (h *healthChecker) readiness(ctx context.Context) []InstanceHealth {
+	if !h.fresh() {
+		h.probeMu.Lock()
+		// 等锁期间其他请求可能已经探测过
+		if !h.fresh() {
+			// 结果由所有调用方共享，不能因为某个客户端断开而把实例都记为 down；
+			// 每个实例的探测仍受 h.timeout 限制
+			h.probeAll(context.WithoutCancel(ctx))
+		}
+		h.probeMu.Unlock()
+	}
+
+	targets := h.targets()
+	out := make([]InstanceHealth, 0, len(targets))
+	for _, t := range targets {
+		out = append(out, h.status(t.kind, t.id, t.svc.Type()))
+	}
+	return out
+}
+
+// unavailable 返回某类服务中启动失败、正在重试的实例
+func (gw *Gateway) unavailable(kind string) []*common.UnavailableError {
+	switch kind {
+	case "rdb":
+		return gw.rdbGateway.Unavailable()
+	case "kv":
+		return gw.kvGateway.Unavailable()
+	case "s3":
+		return gw.s3Gateway.Unavailable()
+	}
+	return nil
+}
+
+func unavailableHealth(u *common.UnavailableError) InstanceHealth {
+	return InstanceHealth{Kind: u.Kind, ID: u.ID, Status: HealthUnavailable, Error: u.Cause}
+}
+
+func (gw *Gateway) readiness(ctx context.Context) (ReadinessResult, int) {
+	instances := gw.health.readiness(ctx)
+	for _, kind := range []string{"rdb", "kv", "s3"} {
+		for _, u := range gw.unavailable(kind) {
+			instances = append(instances, unavailableHealth(u))
+		}
+	}
+	result := ReadinessResult{Status: "ready", Instances: instances}
+	for _, inst := range instances {
+		if inst.Status != HealthUp {
+			result.Status = "not ready"
+			return result, http.StatusServiceUnavailable
+		}
+	}
+	return result, http.StatusOK
+}
+
+// handleReady 所有实例都可用时返回 200，否则 503。
+// 未启用认证或调用方带有 admin:instances 权限时返回每个实例的状态、延迟与错误（隐藏 URL 中的密码），
+// 否则只返回总体状态，因此探针不需要 token
+func (gw *Gateway) handleReady(c *gin.Context) {
+	result, code := gw.readiness(c.Request.Context())
+	if !gw.optionalAllows(c, "admin", "", "instances") {
+		c.JSON(code, gin.H{"status": result.Status})
+		return
+	}
+	for i := range result.Instances {
+		result.Instances[i].Error = common.RedactURL(result.Instances[i].Error)
+	}
+	c.JSON(code, result)
+}
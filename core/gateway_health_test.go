@@ -0,0 +1,57 @@
+package combinator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+func TestReadyDetail(t *testing.T) {
+	conf := &common.Config{
+		Kv: []common.KVConfig{{ID: "cache", URL: "memory://"}},
+		Auth: &common.AuthConfig{Keys: []common.APIKeyConfig{
+			{Name: "ops", Key: "ops-key", Scopes: []string{"admin:instances"}},
+			{Name: "app", Key: "app-key", Scopes: []string{"kv:*:*"}},
+		}},
+	}
+	gw := NewGateway(conf, false)
+	if err := gw.reloadAuth(conf.Auth); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.kvGateway.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer gw.kvGateway.Close()
+	gw.SetHealthCheck(0, 0)
+
+	ready := func(key string) ReadinessResult {
+		req := httptest.NewRequest(http.MethodGet, "/health/ready", nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		gw.g.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", w.Code, w.Body)
+		}
+		var result ReadinessResult
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	// 探针与无权限的调用方只看到总体状态
+	for _, key := range []string{"", "app-key", "wrong"} {
+		if r := ready(key); r.Status != "ready" || len(r.Instances) != 0 {
+			t.Errorf("key %q: unexpected result %+v", key, r)
+		}
+	}
+	r := ready("ops-key")
+	if len(r.Instances) != 1 || r.Instances[0].ID != "cache" || r.Instances[0].Status != HealthUp {
+		t.Errorf("admin:instances: unexpected result %+v", r)
+	}
+}
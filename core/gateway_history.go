@@ -0,0 +1,158 @@
+package combinator
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	common "jabberwocky238/combinator/core/common"
+	history "jabberwocky238/combinator/core/history"
+)
+
+// RevisionSummary 修订列表中不含完整配置的摘要
+type RevisionSummary struct {
+	Rev     int              `json:"rev"`
+	Time    time.Time        `json:"time"`
+	Source  string           `json:"source"`
+	Applied bool             `json:"applied"`
+	Error   string           `json:"error,omitempty"`
+	Diff    []history.Change `json:"diff"`
+}
+
+// EnableHistory 记录每次配置变更到修订历史，需在 Start 之前调用
+func (gw *Gateway) EnableHistory(store *history.Store) {
+	gw.history = store
+}
+
+// recordStart 启动时如果配置与最近一次生效的修订不同，记录为新修订
+func (gw *Gateway) recordStart() {
+	if gw.history == nil {
+		return
+	}
+	gw.reloadMu.Lock()
+	defer gw.reloadMu.Unlock()
+
+	var prev *common.Config
+	if latest, ok := gw.history.LatestApplied(); ok {
+		prev = latest.Config
+	}
+	diff := history.Diff(prev, gw.conf)
+	if prev != nil && len(diff) == 0 {
+		return
+	}
+	gw.appendRevision(history.Revision{Source: "start", Applied: true, Diff: diff, Config: gw.conf})
+}
+
+// recordRevision 记录一次重载（包括失败的重载），调用方需持有 reloadMu
+func (gw *Gateway) recordRevision(source string, prev, conf *common.Config, result *common.ReloadResult) {
+	if gw.history == nil {
+		return
+	}
+	diff := history.Diff(prev, conf)
+	// 内容未变的重载（例如 admin 写回文件后 watch 再次触发）不产生新修订
+	if result.Applied && len(diff) == 0 {
+		return
+	}
+	gw.appendRevision(history.Revision{
+		Source:  source,
+		Applied: result.Applied,
+		Error:   result.Error,
+		Diff:    diff,
+		Config:  conf,
+	})
+}
+
+func (gw *Gateway) appendRevision(rev history.Revision) {
+	rev, err := gw.history.Append(rev)
+	if err != nil {
+		common.Logger.Warnf("Failed to record config revision: %v", err)
+		return
+	}
+	common.Logger.Infof("Recorded config revision %d (%s, %d changes)", rev.Rev, rev.Source, len(rev.Diff))
+}
+
+// Rollback 重新应用某个历史修订的配置，成功后写回配置文件
+func (gw *Gateway) Rollback(rev int) (*common.ReloadResult, error) {
+	if gw.history == nil {
+		return nil, fmt.Errorf("config history is not enabled")
+	}
+	target, ok := gw.history.Get(rev)
+	if !ok {
+		return nil, fmt.Errorf("revision %d not found", rev)
+	}
+
+	gw.reloadMu.Lock()
+	defer gw.reloadMu.Unlock()
+
+	conf, err := target.Config.Clone()
+	if err != nil {
+		return nil, err
+	}
+	common.Logger.Infof("Rolling back to config revision %d", rev)
+	return gw.apply(conf, fmt.Sprintf("rollback:%d", rev), true)
+}
+
+type historyParams struct {
+	Limit int `json:"limit"`
+}
+
+type revisionParams struct {
+	Rev int `json:"rev"`
+}
+
+func (gw *Gateway) handleConfigHistory(params json.RawMessage) (any, *RPCError) {
+	if gw.history == nil {
+		return nil, &RPCError{Code: -32000, Message: "config history is not enabled"}
+	}
+	var p historyParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &RPCError{Code: -32602, Message: "Invalid params"}
+		}
+	}
+
+	revs := gw.history.List()
+	if p.Limit > 0 && len(revs) > p.Limit {
+		revs = revs[len(revs)-p.Limit:]
+	}
+	// 最新的在前
+	result := make([]RevisionSummary, 0, len(revs))
+	for i := len(revs) - 1; i >= 0; i-- {
+		r := revs[i]
+		result = append(result, RevisionSummary{
+			Rev: r.Rev, Time: r.Time, Source: r.Source, Applied: r.Applied, Error: r.Error, Diff: r.Diff,
+		})
+	}
+	return result, nil
+}
+
+func (gw *Gateway) handleConfigRevision(params json.RawMessage) (any, *RPCError) {
+	if gw.history == nil {
+		return nil, &RPCError{Code: -32000, Message: "config history is not enabled"}
+	}
+	var p revisionParams
+	if err := json.Unmarshal(params, &p); err != nil || p.Rev <= 0 {
+		return nil, &RPCError{Code: -32602, Message: "Invalid params"}
+	}
+	rev, ok := gw.history.Get(p.Rev)
+	if !ok {
+		return nil, &RPCError{Code: -32000, Message: fmt.Sprintf("revision %d not found", p.Rev)}
+	}
+	rev.Config = common.RedactConfig(rev.Config)
+	return rev, nil
+}
+
+func (gw *Gateway) handleConfigRollback(params json.RawMessage) (any, *RPCError) {
+	var p revisionParams
+	if err := json.Unmarshal(params, &p); err != nil || p.Rev <= 0 {
+		return nil, &RPCError{Code: -32602, Message: "Invalid params"}
+	}
+	result, err := gw.Rollback(p.Rev)
+	if result == nil {
+		return nil, &RPCError{Code: -32000, Message: err.Error()}
+	}
+	if err != nil {
+		return nil, &RPCError{Code: -32000, Message: err.Error(), Data: result}
+	}
+	return result, nil
+}
@@ -0,0 +1,98 @@
+package combinator
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+// RequestIDHeader 请求 ID 的请求头，客户端提供时沿用，否则由网关生成；总会在响应中返回
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLen = 128
+
+// middlewareLog 为每个请求分配 request ID，并在请求结束时输出一条访问日志，取代 gin 的默认 logger
+func (gw *Gateway) middlewareLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set("request_id", id)
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(common.WithLogFields(c.Request.Context(), logrus.Fields{
+			common.FieldRequestID: id,
+		}))
+
+		c.Next()
+
+		// 健康检查不打印日志
+		if c.Request.URL.Path == "/health" || c.Request.URL.Path == "/health/ready" {
+			return
+		}
+		status := c.Writer.Status()
+		entry := common.Log(c.Request.Context()).WithFields(logrus.Fields{
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"status":     status,
+			"latency_ms": time.Since(start).Milliseconds(),
+			"client_ip":  c.ClientIP(),
+			"bytes":      c.Writer.Size(),
+		})
+		if len(c.Errors) > 0 {
+			entry = entry.WithField("error", c.Errors.Last().Error())
+		}
+		switch {
+		case status >= 500:
+			entry.Error("Request completed")
+		case status >= 400:
+			entry.Warn("Request completed")
+		default:
+			entry.Info("Request completed")
+		}
+	}
+}
+
+// middlewareLogTarget 把 kind、实例 ID 与操作加入请求的日志字段，后端调用的日志也会带上
+func middlewareLogTarget(kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, op := routeTarget(c, kind)
+		if kind == "admin" {
+			id = c.Param("id")
+		}
+		fields := logrus.Fields{common.FieldKind: kind, common.FieldOp: op}
+		if id != "" {
+			fields[common.FieldInstance] = id
+		}
+		c.Request = c.Request.WithContext(common.WithLogFields(c.Request.Context(), fields))
+		c.Next()
+	}
+}
+
+// validRequestID 只接受长度有限的可打印 ID，防止日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, ch := range id {
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		case ch == '-' || ch == '_' || ch == '.' || ch == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
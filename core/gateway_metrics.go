@@ -0,0 +1,195 @@
+package combinator
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+// gatewayMetrics 每个 Gateway 独立的 Prometheus 指标
+type gatewayMetrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	reloads         *prometheus.CounterVec
+	lastReload      prometheus.Gauge
+}
+
+func newGatewayMetrics(gw *Gateway) *gatewayMetrics {
+	m := &gatewayMetrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "combinator",
+			Name:      "requests_total",
+			Help:      "Total number of gateway requests by service kind, instance, operation and status.",
+		}, []string{"kind", "instance", "op", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "combinator",
+			Name:      "request_duration_seconds",
+			Help:      "Gateway request latency by service kind, instance and operation.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"kind", "instance", "op"}),
+		reloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "combinator",
+			Name:      "config_reloads_total",
+			Help:      "Total number of configuration reloads by result.",
+		}, []string{"result"}),
+		lastReload: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "combinator",
+			Name:      "config_last_reload_success_timestamp_seconds",
+			Help:      "Unix timestamp of the last successful configuration reload.",
+		}),
+	}
+	// 预先创建 label，使从未失败过时也能看到 0
+	m.reloads.WithLabelValues("success")
+	m.reloads.WithLabelValues("failure")
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.reloads,
+		m.lastReload,
+		&instanceCollector{gw: gw},
+	)
+	return m
+}
+
+func (m *gatewayMetrics) observeReload(err error) {
+	if err != nil {
+		m.reloads.WithLabelValues("failure").Inc()
+		return
+	}
+	m.reloads.WithLabelValues("success").Inc()
+	m.lastReload.SetToCurrentTime()
+}
+
+// middlewareMetrics 记录请求数与耗时，放在认证之前以统计 401/403
+func (gw *Gateway) middlewareMetrics(kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		id, op := routeTarget(c, kind)
+		// 只使用已配置的实例 ID 作为 label，避免任意请求头撑爆基数
+		if !gw.hasInstance(kind, id) {
+			id = "unknown"
+		}
+		if c.FullPath() == "" {
+			op = "unknown"
+		}
+
+		gw.metrics.requests.WithLabelValues(kind, id, op, strconv.Itoa(c.Writer.Status())).Inc()
+		gw.metrics.requestDuration.WithLabelValues(kind, id, op).Observe(time.Since(start).Seconds())
+	}
+}
+
+func (gw *Gateway) hasInstance(kind, id string) bool {
+	switch kind {
+	case "rdb":
+		return gw.rdbGateway.Has(id)
+	case "kv":
+		return gw.kvGateway.Has(id)
+	case "s3":
+		return gw.s3Gateway.Has(id)
+	default:
+		return id == ""
+	}
+}
+
+// SetupMetricsAPI 以 Prometheus 文本格式暴露 /metrics
+func (gw *Gateway) SetupMetricsAPI() {
+	handler := promhttp.HandlerFor(gw.metrics.registry, promhttp.HandlerOpts{})
+	gw.g.GET("/metrics", gw.middlewareAuth("admin"), gin.WrapH(handler))
+}
+
+var (
+	instancesDesc = prometheus.NewDesc(
+		"combinator_instances", "Number of available instances by service kind.",
+		[]string{"kind"}, nil)
+	unavailableDesc = prometheus.NewDesc(
+		"combinator_instances_unavailable", "Number of configured instances that failed to start and are being retried.",
+		[]string{"kind"}, nil)
+	breakerStateDesc = prometheus.NewDesc(
+		"combinator_circuit_breaker_state", "Circuit breaker state per instance: 0 closed, 1 half-open, 2 open.",
+		[]string{"kind", "instance"}, nil)
+	breakerTripsDesc = prometheus.NewDesc(
+		"combinator_circuit_breaker_trips_total", "Number of times the circuit breaker opened.",
+		[]string{"kind", "instance"}, nil)
+	breakerRejectedDesc = prometheus.NewDesc(
+		"combinator_circuit_breaker_rejected_total", "Number of calls rejected without reaching the backend.",
+		[]string{"kind", "instance"}, nil)
+	poolOpenDesc = prometheus.NewDesc(
+		"combinator_rdb_pool_open_connections", "Number of established connections, both in use and idle.",
+		[]string{"instance"}, nil)
+	poolInUseDesc = prometheus.NewDesc(
+		"combinator_rdb_pool_in_use_connections", "Number of connections currently in use.",
+		[]string{"instance"}, nil)
+	poolIdleDesc = prometheus.NewDesc(
+		"combinator_rdb_pool_idle_connections", "Number of idle connections.",
+		[]string{"instance"}, nil)
+	poolMaxOpenDesc = prometheus.NewDesc(
+		"combinator_rdb_pool_max_open_connections", "Maximum number of open connections, 0 means unlimited.",
+		[]string{"instance"}, nil)
+	poolWaitCountDesc = prometheus.NewDesc(
+		"combinator_rdb_pool_wait_count_total", "Total number of connections waited for.",
+		[]string{"instance"}, nil)
+	poolWaitDurationDesc = prometheus.NewDesc(
+		"combinator_rdb_pool_wait_duration_seconds_total", "Total time blocked waiting for a new connection.",
+		[]string{"instance"}, nil)
+)
+
+// instanceCollector 在抓取时读取当前实例与连接池状态
+type instanceCollector struct {
+	gw *Gateway
+}
+
+func (ic *instanceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- instancesDesc
+	ch <- unavailableDesc
+	ch <- breakerStateDesc
+	ch <- breakerTripsDesc
+	ch <- breakerRejectedDesc
+	ch <- poolOpenDesc
+	ch <- poolInUseDesc
+	ch <- poolIdleDesc
+	ch <- poolMaxOpenDesc
+	ch <- poolWaitCountDesc
+	ch <- poolWaitDurationDesc
+}
+
+func (ic *instanceCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(instancesDesc, prometheus.GaugeValue, float64(ic.gw.rdbGateway.Count()), "rdb")
+	ch <- prometheus.MustNewConstMetric(instancesDesc, prometheus.GaugeValue, float64(ic.gw.kvGateway.Count()), "kv")
+	ch <- prometheus.MustNewConstMetric(instancesDesc, prometheus.GaugeValue, float64(ic.gw.s3Gateway.Count()), "s3")
+	for _, kind := range []string{"rdb", "kv", "s3"} {
+		ch <- prometheus.MustNewConstMetric(unavailableDesc, prometheus.GaugeValue, float64(len(ic.gw.unavailable(kind))), kind)
+	}
+
+	for _, t := range ic.gw.health.targets() {
+		b := common.BreakerOf(t.svc)
+		if b == nil {
+			continue
+		}
+		st := b.Status()
+		ch <- prometheus.MustNewConstMetric(breakerStateDesc, prometheus.GaugeValue, float64(b.State()), t.kind, t.id)
+		ch <- prometheus.MustNewConstMetric(breakerTripsDesc, prometheus.CounterValue, float64(st.Trips), t.kind, t.id)
+		ch <- prometheus.MustNewConstMetric(breakerRejectedDesc, prometheus.CounterValue, float64(st.Rejected), t.kind, t.id)
+	}
+
+	for id, st := range ic.gw.rdbGateway.PoolStats() {
+		ch <- prometheus.MustNewConstMetric(poolOpenDesc, prometheus.GaugeValue, float64(st.OpenConnections), id)
+		ch <- prometheus.MustNewConstMetric(poolInUseDesc, prometheus.GaugeValue, float64(st.InUse), id)
+		ch <- prometheus.MustNewConstMetric(poolIdleDesc, prometheus.GaugeValue, float64(st.Idle), id)
+		ch <- prometheus.MustNewConstMetric(poolMaxOpenDesc, prometheus.GaugeValue, float64(st.MaxOpenConnections), id)
+		ch <- prometheus.MustNewConstMetric(poolWaitCountDesc, prometheus.CounterValue, float64(st.WaitCount), id)
+		ch <- prometheus.MustNewConstMetric(poolWaitDurationDesc, prometheus.CounterValue, st.WaitDuration.Seconds(), id)
+	}
+}
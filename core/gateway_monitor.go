@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+
+	common "jabberwocky238/combinator/core/common"
 )
 
 type JSONRPCRequest struct {
@@ -30,7 +33,7 @@ type RPCError struct {
 
 // API 监听
 func (gw *Gateway) SetupMonitorAPI() {
-	gw.g.POST("/monitor", func(c *gin.Context) {
+	gw.g.POST("/monitor", middlewareLogTarget("admin"), gw.middlewareMetrics("admin"), gw.middlewareAuth("admin"), func(c *gin.Context) {
 		body, err := io.ReadAll(c.Request.Body)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, JSONRPCResponse{
@@ -60,6 +63,11 @@ func (gw *Gateway) SetupMonitorAPI() {
 			return
 		}
 
+		// admin:monitor 只用于查看，回滚会修改线上配置，需要单独的 admin:rollback
+		if op, ok := rpcWriteOps[req.Method]; ok && !gw.authorizeOp(c, "admin", "", op) {
+			return
+		}
+
 		result, rpcErr := gw.handleRPCMethod(req.Method, req.Params)
 		if rpcErr != nil {
 			c.JSON(http.StatusOK, JSONRPCResponse{
@@ -79,13 +87,47 @@ func (gw *Gateway) SetupMonitorAPI() {
 }
 
 type ServiceInfo struct {
-	ID   string `json:"id"`
-	Type string `json:"type"`
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Status    string    `json:"status"`
+	LatencyMs float64   `json:"latencyMs"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt,omitzero"`
+	// 以下字段仅在 status 为 unavailable 时出现
+	Attempts  int       `json:"attempts,omitempty"`
+	Since     time.Time `json:"since,omitzero"`
+	NextRetry time.Time `json:"nextRetry,omitzero"`
+
+	Breaker *common.BreakerStatus `json:"breaker,omitempty"`
 }
 
 type ServiceListResult struct {
 	RDB []ServiceInfo `json:"rdb"`
 	KV  []ServiceInfo `json:"kv"`
+	S3  []ServiceInfo `json:"s3"`
+}
+
+// serviceInfo 合并后台健康探测的最近结果
+func (gw *Gateway) serviceInfo(kind, id string, svc common.Service) ServiceInfo {
+	h := gw.health.status(kind, id, svc.Type())
+	info := ServiceInfo{
+		ID:        id,
+		Type:      svc.Type(),
+		Status:    h.Status,
+		LatencyMs: h.LatencyMs,
+		Error:     h.Error,
+		CheckedAt: h.CheckedAt,
+	}
+	if b := common.BreakerOf(svc); b != nil {
+		st := b.Status()
+		info.Breaker = &st
+	}
+	return info
+}
+
+// rpcWriteOps 会修改状态的 RPC 方法及其额外需要的 admin 操作
+var rpcWriteOps = map[string]string{
+	"config.rollback": "rollback",
 }
 
 func (gw *Gateway) handleRPCMethod(method string, params json.RawMessage) (any, *RPCError) {
@@ -94,6 +136,12 @@ func (gw *Gateway) handleRPCMethod(method string, params json.RawMessage) (any,
 		return "pong", nil
 	case "service.list":
 		return gw.handleServiceList()
+	case "config.history":
+		return gw.handleConfigHistory(params)
+	case "config.revision":
+		return gw.handleConfigRevision(params)
+	case "config.rollback":
+		return gw.handleConfigRollback(params)
 	default:
 		return nil, &RPCError{Code: -32601, Message: "Method not found"}
 	}
@@ -103,20 +151,39 @@ func (gw *Gateway) handleServiceList() (*ServiceListResult, *RPCError) {
 	result := &ServiceListResult{
 		RDB: make([]ServiceInfo, 0),
 		KV:  make([]ServiceInfo, 0),
+		S3:  make([]ServiceInfo, 0),
 	}
 
-	for id, rdb := range gw.rdbGateway.RdbMap {
-		result.RDB = append(result.RDB, ServiceInfo{
-			ID:   id,
-			Type: rdb.Type(),
-		})
-	}
+	gw.rdbGateway.Range(func(id string, rdb common.RDB) bool {
+		result.RDB = append(result.RDB, gw.serviceInfo("rdb", id, rdb))
+		return true
+	})
 
-	for id, kv := range gw.kvGateway.KvMap {
-		result.KV = append(result.KV, ServiceInfo{
-			ID:   id,
-			Type: kv.Type(),
-		})
+	gw.kvGateway.Range(func(id string, kv common.KV) bool {
+		result.KV = append(result.KV, gw.serviceInfo("kv", id, kv))
+		return true
+	})
+
+	gw.s3Gateway.Range(func(id string, s3 common.S3) bool {
+		result.S3 = append(result.S3, gw.serviceInfo("s3", id, s3))
+		return true
+	})
+
+	// 启动失败、正在后台重试的实例
+	for _, list := range []struct {
+		kind string
+		out  *[]ServiceInfo
+	}{{"rdb", &result.RDB}, {"kv", &result.KV}, {"s3", &result.S3}} {
+		for _, u := range gw.unavailable(list.kind) {
+			*list.out = append(*list.out, ServiceInfo{
+				ID:        u.ID,
+				Status:    HealthUnavailable,
+				Error:     u.Cause,
+				Attempts:  u.Attempts,
+				Since:     u.Since,
+				NextRetry: u.NextRetry,
+			})
+		}
 	}
 
 	return result, nil
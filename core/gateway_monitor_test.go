@@ -0,0 +1,45 @@
+package combinator
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+func TestMonitorRollbackScope(t *testing.T) {
+	conf := &common.Config{Auth: &common.AuthConfig{Keys: []common.APIKeyConfig{
+		{Name: "viewer", Key: "viewer-key", Scopes: []string{"admin:monitor"}},
+		{Name: "operator", Key: "operator-key", Scopes: []string{"admin:monitor", "admin:rollback"}},
+	}}}
+	gw := NewGateway(conf, false)
+	if err := gw.reloadAuth(conf.Auth); err != nil {
+		t.Fatal(err)
+	}
+	gw.SetupMonitorAPI()
+
+	call := func(key, method string) int {
+		body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":{"rev":1}}`
+		req := httptest.NewRequest(http.MethodPost, "/monitor", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		gw.g.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := call("viewer-key", "ping"); code != http.StatusOK {
+		t.Errorf("ping with admin:monitor: got %d", code)
+	}
+	if code := call("viewer-key", "config.history"); code != http.StatusOK {
+		t.Errorf("config.history with admin:monitor: got %d", code)
+	}
+	if code := call("viewer-key", "config.rollback"); code != http.StatusForbidden {
+		t.Errorf("config.rollback with admin:monitor only: got %d, want 403", code)
+	}
+	// 有 admin:rollback 时进入回滚（未启用历史，返回 RPC 错误）
+	if code := call("operator-key", "config.rollback"); code != http.StatusOK {
+		t.Errorf("config.rollback with admin:rollback: got %d", code)
+	}
+}
@@ -0,0 +1,102 @@
+package combinator
+
+import (
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	auth "jabberwocky238/combinator/core/auth"
+	common "jabberwocky238/combinator/core/common"
+	kvModule "jabberwocky238/combinator/core/kv"
+	ratelimit "jabberwocky238/combinator/core/ratelimit"
+)
+
+// checkRateLimitStore 限流使用的共享存储必须是配置中可写且支持按 key 过期的 KV 实例
+func checkRateLimitStore(conf *common.Config) error {
+	rl := conf.RateLimit
+	if rl == nil || rl.Store == "" {
+		return nil
+	}
+	i := slices.IndexFunc(conf.Kv, func(c common.KVConfig) bool { return c.ID == rl.Store })
+	if i < 0 {
+		return common.GlobalErrorBuilder.With("ratelimit").Error("store KV %s not found", rl.Store)
+	}
+	// URL 与选项本身的错误由实例检查报告
+	parsed, opts, err := kvModule.ParseOptions(conf.Kv[i])
+	if err != nil {
+		return nil
+	}
+	// 令牌桶状态需要按 key 过期，rocksdb 不支持
+	if parsed.Type == "rocksdb" {
+		return common.GlobalErrorBuilder.With("ratelimit").Error("store KV %s: rocksdb cannot expire keys, use memory or redis", rl.Store)
+	}
+	// 只读实例上每次写入都会失败，限流会静默失效
+	if opts.ReadOnly {
+		return common.GlobalErrorBuilder.With("ratelimit").Error("store KV %s is read-only", rl.Store)
+	}
+	return nil
+}
+
+// newRateLimiter 根据配置构建限流器，store 指向的 KV 必须出现在同一份配置中
+func (gw *Gateway) newRateLimiter(conf *common.Config) (*ratelimit.Limiter, error) {
+	rl := conf.RateLimit
+	var store ratelimit.Store = gw.limitStore
+	if rl != nil && rl.Store != "" {
+		storeID := rl.Store
+		if err := checkRateLimitStore(conf); err != nil {
+			return nil, err
+		}
+		// 每次请求重新获取实例，重载后仍指向最新的 KV
+		store = ratelimit.NewKVStore(func() common.KV {
+			kv, _ := gw.kvGateway.Get(storeID)
+			return kv
+		}, gw.limitStore)
+	}
+
+	l, err := ratelimit.New(rl, store)
+	if err != nil {
+		return nil, err
+	}
+	if l != nil {
+		common.Logger.Infof("Rate limit enabled with %d rules", len(rl.Rules))
+	}
+	return l, nil
+}
+
+// middlewareRateLimit 放在认证之后，以便按 API key 限流
+func (gw *Gateway) middlewareRateLimit(kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		l := gw.limiter.Load()
+		if l == nil {
+			c.Next()
+			return
+		}
+
+		id, op := routeTarget(c, kind)
+		req := ratelimit.Request{
+			Kind:     kind,
+			ID:       id,
+			Op:       op,
+			ClientIP: c.ClientIP(),
+		}
+		if p, ok := c.Get(auth.ContextPrincipal); ok {
+			req.APIKey = p.(*auth.Principal).Name
+		}
+
+		d := l.Allow(c.Request.Context(), req)
+		if !d.Allowed {
+			retry := int(math.Ceil(d.RetryAfter.Seconds()))
+			if retry < 1 {
+				retry = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retry))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded", "rule": d.Rule})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
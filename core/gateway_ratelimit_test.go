@@ -0,0 +1,25 @@
+package combinator
+
+import (
+	"testing"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+func TestCheckRateLimitStore(t *testing.T) {
+	rl := &common.RateLimitConfig{Store: "rl", Rules: []common.RateLimitRule{{Key: "ip", Rate: 1}}}
+	for _, tc := range []struct {
+		kv common.KVConfig
+		ok bool
+	}{
+		{common.KVConfig{ID: "rl", URL: "memory://"}, true},
+		{common.KVConfig{ID: "other", URL: "memory://"}, false},
+		{common.KVConfig{ID: "rl", URL: "rocksdb:///tmp/rl"}, false},
+		{common.KVConfig{ID: "rl", URL: "memory://", Metadata: map[string]any{"readOnly": true}}, false},
+	} {
+		err := checkRateLimitStore(&common.Config{Kv: []common.KVConfig{tc.kv}, RateLimit: rl})
+		if (err == nil) != tc.ok {
+			t.Errorf("%+v: got %v", tc.kv, err)
+		}
+	}
+}
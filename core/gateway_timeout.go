@@ -0,0 +1,73 @@
+package combinator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	auth "jabberwocky238/combinator/core/auth"
+	common "jabberwocky238/combinator/core/common"
+)
+
+type timeoutRule struct {
+	target  auth.Scope
+	timeout time.Duration
+}
+
+// timeoutPolicy 按路由决定请求的 deadline
+type timeoutPolicy struct {
+	def   time.Duration
+	rules []timeoutRule
+}
+
+func newTimeoutPolicy(conf *common.TimeoutConfig) (*timeoutPolicy, error) {
+	p := &timeoutPolicy{}
+	if conf == nil {
+		return p, nil
+	}
+	if conf.Default < 0 {
+		return nil, fmt.Errorf("timeouts: default must not be negative")
+	}
+	p.def = time.Duration(conf.Default) * time.Millisecond
+	for i, r := range conf.Rules {
+		if r.Timeout < 0 {
+			return nil, fmt.Errorf("timeouts rule %d: timeout must not be negative", i)
+		}
+		target, err := auth.ParseScope(r.Target)
+		if err != nil {
+			return nil, fmt.Errorf("timeouts rule %d: %w", i, err)
+		}
+		p.rules = append(p.rules, timeoutRule{target: target, timeout: time.Duration(r.Timeout) * time.Millisecond})
+	}
+	return p, nil
+}
+
+func (p *timeoutPolicy) lookup(kind, id, op string) time.Duration {
+	for _, r := range p.rules {
+		if r.target.Match(kind, id, op) {
+			return r.timeout
+		}
+	}
+	return p.def
+}
+
+// middlewareTimeout 给请求 context 加上 deadline，各服务把该 context 一直传到驱动；
+// 客户端断开时 net/http 会取消同一个 context
+func (gw *Gateway) middlewareTimeout(kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := gw.timeouts.Load()
+		if p == nil {
+			c.Next()
+			return
+		}
+		id, op := routeTarget(c, kind)
+		if d := p.lookup(kind, id, op); d > 0 {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+			defer cancel()
+			c.Request = c.Request.WithContext(ctx)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,157 @@
+package combinator
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+// TLSOptions 网关 TLS 监听配置，设置 ClientCAFile 时启用双向 TLS
+type TLSOptions struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+// certReloader 持有当前证书，文件内容变化时重新加载
+type certReloader struct {
+	opts TLSOptions
+
+	mu    sync.Mutex // 串行化 reload
+	state atomic.Pointer[tlsState]
+}
+
+// tlsState 一次加载的证书与客户端 CA，整体替换，握手时无需加锁
+type tlsState struct {
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	hash      [32]byte
+}
+
+func newCertReloader(opts TLSOptions) (*certReloader, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, fmt.Errorf("both TLS cert and key files are required")
+	}
+	r := &certReloader{opts: opts}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload 重新读取证书文件，内容未变时不做任何事
+func (r *certReloader) reload() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	certPEM, err := os.ReadFile(r.opts.CertFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to read TLS cert: %w", err)
+	}
+	keyPEM, err := os.ReadFile(r.opts.KeyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to read TLS key: %w", err)
+	}
+	var caPEM []byte
+	if r.opts.ClientCAFile != "" {
+		caPEM, err = os.ReadFile(r.opts.ClientCAFile)
+		if err != nil {
+			return false, fmt.Errorf("failed to read TLS client CA: %w", err)
+		}
+	}
+
+	h := sha256.New()
+	h.Write(certPEM)
+	h.Write(keyPEM)
+	h.Write(caPEM)
+	var newHash [32]byte
+	copy(newHash[:], h.Sum(nil))
+
+	if cur := r.state.Load(); cur != nil && cur.hash == newHash {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, fmt.Errorf("invalid TLS key pair: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if caPEM != nil {
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return false, fmt.Errorf("no certificates found in TLS client CA file")
+		}
+	}
+
+	r.state.Store(&tlsState{cert: &cert, clientCAs: pool, hash: newHash})
+	return true, nil
+}
+
+// tlsConfig 返回监听使用的唯一配置，保留 http.Server 设置的 NextProtos (h2) 与会话票据；
+// 证书与客户端 CA 在每次握手时从当前状态读取，因此重新加载后无需重启监听
+func (r *certReloader) tlsConfig() *tls.Config {
+	conf := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return r.state.Load().cert, nil
+		},
+	}
+	if r.opts.ClientCAFile != "" {
+		// 要求客户端出示证书，证书链由 verifyClient 按当前 CA 校验
+		conf.ClientAuth = tls.RequireAnyClientCert
+		conf.VerifyConnection = r.verifyClient
+	}
+	return conf
+}
+
+// verifyClient 与 RequireAndVerifyClientCert 的校验相同，只是 CA 来自最近一次加载；
+// 会话恢复时同样会调用，CA 轮换后旧 CA 签发的证书无法再恢复会话
+func (r *certReloader) verifyClient(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("client certificate required")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         r.state.Load().clientCAs,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, c := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	if _, err := cs.PeerCertificates[0].Verify(opts); err != nil {
+		return fmt.Errorf("failed to verify client certificate: %w", err)
+	}
+	return nil
+}
+
+// EnableTLS 让 Start 以 HTTPS 监听，必须在 Start 之前调用
+func (gw *Gateway) EnableTLS(opts TLSOptions) error {
+	r, err := newCertReloader(opts)
+	if err != nil {
+		return err
+	}
+	gw.tls = r
+	return nil
+}
+
+// ReloadTLS 重新加载证书文件，未启用 TLS 时为空操作
+func (gw *Gateway) ReloadTLS() error {
+	if gw.tls == nil {
+		return nil
+	}
+	changed, err := gw.tls.reload()
+	if err != nil {
+		return err
+	}
+	if changed {
+		common.Logger.Infof("TLS certificate reloaded from %s", gw.tls.opts.CertFile)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package combinator
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	trace "jabberwocky238/combinator/core/trace"
+)
+
+// middlewareTrace 为每个请求创建 server span，并沿用上游的 W3C traceparent
+func (gw *Gateway) middlewareTrace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 健康检查不产生 span
+		if c.Request.URL.Path == "/health" || c.Request.URL.Path == "/health/ready" {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		if sc, err := trace.ParseTraceparent(c.GetHeader("traceparent")); err == nil {
+			ctx = trace.ContextWithRemote(ctx, sc)
+		}
+
+		ctx, span := trace.Start(ctx, c.Request.Method+" "+c.Request.URL.Path, trace.SpanKindServer)
+		defer span.Finish()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Header("traceparent", span.Context.Traceparent())
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttr("http.request.method", c.Request.Method)
+		span.SetAttr("http.route", c.FullPath())
+		span.SetAttr("http.response.status_code", status)
+		if id := c.GetString("request_id"); id != "" {
+			span.SetAttr("combinator.request_id", id)
+		}
+		for kind, header := range instanceHeaders {
+			if id := c.GetHeader(header); id != "" {
+				span.SetAttr("combinator."+kind+".id", id)
+			}
+		}
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		} else if status >= 500 {
+			span.RecordError(fmt.Errorf("HTTP %d", status))
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package history
+
+import (
+	"encoding/json"
+	"reflect"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+// ChangeOp 单项变更类型
+type ChangeOp string
+
+const (
+	OpAdded   ChangeOp = "added"
+	OpRemoved ChangeOp = "removed"
+	OpChanged ChangeOp = "changed"
+)
+
+// Change 两份配置之间的一项差异
+// 实例变更的 Section 为 rdb / kv / s3，Before/After 为 URL；
+// auth、rateLimit 等整体比较，只记录 Section 与 Op
+type Change struct {
+	Section string   `json:"section"`
+	ID      string   `json:"id,omitempty"`
+	Op      ChangeOp `json:"op"`
+	Before  string   `json:"before,omitempty"`
+	After   string   `json:"after,omitempty"`
+}
+
+// Diff 计算从 old 到 new 的变更，old 为 nil 时视为空配置
+func Diff(old, new *common.Config) []Change {
+	if old == nil {
+		old = &common.Config{}
+	}
+	if new == nil {
+		new = &common.Config{}
+	}
+
+	changes := []Change{}
+	changes = append(changes, diffInstances("rdb", old.Rdb, new.Rdb)...)
+	changes = append(changes, diffInstances("kv", old.Kv, new.Kv)...)
+	changes = append(changes, diffInstances("s3", old.S3, new.S3)...)
+	changes = append(changes, diffSection("auth", old.Auth, new.Auth)...)
+	changes = append(changes, diffSection("rateLimit", old.RateLimit, new.RateLimit)...)
+	changes = append(changes, diffSection("circuitBreaker", old.CircuitBreaker, new.CircuitBreaker)...)
+	changes = append(changes, diffSection("timeouts", old.Timeouts, new.Timeouts)...)
+	changes = append(changes, diffSection("cors", old.CORS, new.CORS)...)
+	return changes
+}
+
+// instanceConfig 实例配置按 InstanceID 对应，按 Same 判断是否变化，URL 用于显示
+type instanceConfig[C any] interface {
+	common.InstanceConfig[C]
+	InstanceURL() string
+}
+
+func toInstances[C instanceConfig[C]](list []C) (map[string]C, []string) {
+	m := make(map[string]C, len(list))
+	order := make([]string, 0, len(list))
+	for _, c := range list {
+		id := c.InstanceID()
+		if _, dup := m[id]; !dup {
+			order = append(order, id)
+		}
+		m[id] = c
+	}
+	return m, order
+}
+
+func diffInstances[C instanceConfig[C]](section string, old, new []C) []Change {
+	oldMap, oldOrder := toInstances(old)
+	newMap, newOrder := toInstances(new)
+
+	var changes []Change
+	for _, id := range newOrder {
+		n := newMap[id]
+		o, ok := oldMap[id]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Section: section, ID: id, Op: OpAdded, After: common.RedactURL(n.InstanceURL())})
+		case !o.Same(n):
+			changes = append(changes, Change{Section: section, ID: id, Op: OpChanged, Before: common.RedactURL(o.InstanceURL()), After: common.RedactURL(n.InstanceURL())})
+		}
+	}
+	for _, id := range oldOrder {
+		if _, ok := newMap[id]; !ok {
+			changes = append(changes, Change{Section: section, ID: id, Op: OpRemoved, Before: common.RedactURL(oldMap[id].InstanceURL())})
+		}
+	}
+	return changes
+}
+
+func diffSection[T any](section string, old, new *T) []Change {
+	switch {
+	case old == nil && new == nil:
+		return nil
+	case old == nil:
+		return []Change{{Section: section, Op: OpAdded}}
+	case new == nil:
+		return []Change{{Section: section, Op: OpRemoved}}
+	case !sameJSON(old, new):
+		return []Change{{Section: section, Op: OpChanged}}
+	}
+	return nil
+}
+
+// sameJSON 按 JSON 表示比较，忽略 map 与 struct 的类型差异
+func sameJSON(a, b any) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return reflect.DeepEqual(a, b)
+	}
+	return string(ja) == string(jb)
+}
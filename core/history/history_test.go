@@ -0,0 +1,75 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+func TestDiff(t *testing.T) {
+	old := &common.Config{
+		Rdb: []common.RDBConfig{{ID: "a", URL: "sqlite:///a.db"}, {ID: "b", URL: "sqlite:///b.db"}},
+		Kv:  []common.KVConfig{{ID: "k", URL: "memory://"}},
+	}
+	new := &common.Config{
+		Rdb:  []common.RDBConfig{{ID: "a", URL: "sqlite:///a2.db"}},
+		Kv:   []common.KVConfig{{ID: "k", URL: "memory://"}, {ID: "r", URL: "redis://localhost"}},
+		Auth: &common.AuthConfig{},
+	}
+
+	got := Diff(old, new)
+	want := []Change{
+		{Section: "rdb", ID: "a", Op: OpChanged, Before: "sqlite:///a.db", After: "sqlite:///a2.db"},
+		{Section: "rdb", ID: "b", Op: OpRemoved, Before: "sqlite:///b.db"},
+		{Section: "kv", ID: "r", Op: OpAdded, After: "redis://localhost"},
+		{Section: "auth", Op: OpAdded},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d changes %+v, want %d", len(got), got, len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("change %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if d := Diff(new, new); len(d) != 0 {
+		t.Errorf("expected no changes, got %+v", d)
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store, err := Open(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		conf := &common.Config{Kv: []common.KVConfig{{ID: "k", URL: "memory://"}}}
+		if _, err := store.Append(Revision{Source: "file", Applied: i != 4, Config: conf}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 重新打开，只保留最新的 3 个修订
+	store, err = Open(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revs := store.List()
+	if len(revs) != 3 || revs[0].Rev != 3 || revs[2].Rev != 5 {
+		t.Fatalf("unexpected revisions after reopen: %+v", revs)
+	}
+	if latest, ok := store.LatestApplied(); !ok || latest.Rev != 4 {
+		t.Errorf("latest applied = %d, want 4", latest.Rev)
+	}
+	if _, ok := store.Get(1); ok {
+		t.Error("trimmed revision still present")
+	}
+
+	rev, err := store.Append(Revision{Source: "admin", Applied: true})
+	if err != nil || rev.Rev != 6 {
+		t.Errorf("append after reopen: rev %d, err %v", rev.Rev, err)
+	}
+}
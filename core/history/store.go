@@ -0,0 +1,167 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+// DefaultLimit 默认保留的修订数量
+const DefaultLimit = 100
+
+// Revision 一次配置变更的记录
+type Revision struct {
+	Rev     int            `json:"rev"`
+	Time    time.Time      `json:"time"`
+	Source  string         `json:"source"` // start, file, api, admin, rollback:<rev>
+	Applied bool           `json:"applied"`
+	Error   string         `json:"error,omitempty"`
+	Diff    []Change       `json:"diff"`
+	Config  *common.Config `json:"config"`
+}
+
+// Store 以 JSON Lines 保存修订历史，每行一个 Revision
+type Store struct {
+	mu    sync.Mutex
+	path  string
+	limit int
+	revs  []Revision
+}
+
+// Open 打开（或创建）历史文件，limit <= 0 时使用 DefaultLimit
+func Open(path string, limit int) (*Store, error) {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	s := &Store{path: path, limit: limit}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var rev Revision
+		if err := json.Unmarshal(scanner.Bytes(), &rev); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		s.revs = append(s.revs, rev)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Path 返回历史文件路径
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Append 分配修订号并写入历史，超出保留数量时丢弃最旧的修订
+func (s *Store) Append(rev Revision) (Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rev.Rev = 1
+	if n := len(s.revs); n > 0 {
+		rev.Rev = s.revs[n-1].Rev + 1
+	}
+	if rev.Time.IsZero() {
+		rev.Time = time.Now()
+	}
+	if rev.Diff == nil {
+		rev.Diff = []Change{}
+	}
+
+	s.revs = append(s.revs, rev)
+	if len(s.revs) > s.limit {
+		s.revs = append([]Revision(nil), s.revs[len(s.revs)-s.limit:]...)
+		return rev, s.rewrite()
+	}
+	return rev, s.appendLine(rev)
+}
+
+func (s *Store) appendLine(rev Revision) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	buf, err := json.Marshal(rev)
+	if err != nil {
+		return err
+	}
+	// 配置中可能含有密钥，仅当前用户可读
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(buf, '\n'))
+	return err
+}
+
+func (s *Store) rewrite() error {
+	var buf bytes.Buffer
+	for _, rev := range s.revs {
+		line, err := json.Marshal(rev)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// List 按修订号从旧到新返回所有修订
+func (s *Store) List() []Revision {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Revision(nil), s.revs...)
+}
+
+// Get 返回指定修订
+func (s *Store) Get(rev int) (Revision, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.revs {
+		if r.Rev == rev {
+			return r, true
+		}
+	}
+	return Revision{}, false
+}
+
+// LatestApplied 返回最近一次成功应用的修订
+func (s *Store) LatestApplied() (Revision, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.revs) - 1; i >= 0; i-- {
+		if s.revs[i].Applied {
+			return s.revs[i], true
+		}
+	}
+	return Revision{}, false
+}
@@ -0,0 +1,43 @@
+package kv
+
+import (
+	"context"
+	"time"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+// breakerKV 经过熔断器调用底层 KV，Start / Close / Ping 不经过熔断器
+type breakerKV struct {
+	common.KV
+	breaker *common.Breaker
+}
+
+func newBreakerKV(kv common.KV, b *common.Breaker) *breakerKV {
+	return &breakerKV{KV: kv, breaker: b}
+}
+
+func (k *breakerKV) Breaker() *common.Breaker { return k.breaker }
+func (k *breakerKV) MaxValueSize() int64      { return common.MaxValueSizeOf(k.KV) }
+
+func (k *breakerKV) Get(ctx context.Context, key string) ([]byte, error) {
+	return common.Call(k.breaker, ctx, func(ctx context.Context) ([]byte, error) {
+		return k.KV.Get(ctx, key)
+	})
+}
+
+func (k *breakerKV) Set(ctx context.Context, key string, value []byte) error {
+	return k.breaker.Do(ctx, func(ctx context.Context) error {
+		return k.KV.Set(ctx, key, value)
+	})
+}
+
+func (k *breakerKV) SetTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	e, ok := k.KV.(common.ExpiringKV)
+	if !ok {
+		return common.ErrTTLUnsupported
+	}
+	return k.breaker.Do(ctx, func(ctx context.Context) error {
+		return e.SetTTL(ctx, key, value, ttl)
+	})
+}
@@ -6,8 +6,9 @@ import (
 	common "jabberwocky238/combinator/core/common"
 )
 
-// KVFactory is a function that creates a KV instance from a parsed URL
-type KVFactory func(*ParsedKVURL) (common.KV, error)
+// KVFactory is a function that creates a KV instance from a parsed URL and its options;
+// backend-specific options (TTL, pool settings) are applied by the factory itself
+type KVFactory func(*ParsedKVURL, *Options) (common.KV, error)
 
 var kvFactories = make(map[string]KVFactory)
 
@@ -16,11 +17,19 @@ func RegisterKVFactory(kvType string, factory KVFactory) {
 	kvFactories[kvType] = factory
 }
 
-// CreateKV creates a KV instance based on the parsed URL
-func CreateKV(parsed *ParsedKVURL) (common.KV, error) {
+// CreateKV creates a KV instance based on the parsed URL and options;
+// key prefix, value size limit and read-only are applied here for every backend
+func CreateKV(parsed *ParsedKVURL, opts *Options) (common.KV, error) {
 	factory, ok := kvFactories[parsed.Type]
 	if !ok {
 		return nil, fmt.Errorf("unsupported KV type: %s", parsed.Type)
 	}
-	return factory(parsed)
+	kv, err := factory(parsed, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.KeyPrefix != "" || opts.MaxValueSize > 0 || opts.ReadOnly {
+		kv = &optionsKV{KV: kv, opts: opts}
+	}
+	return kv, nil
 }
@@ -1,51 +1,32 @@
 package kv
 
 import (
+	"errors"
+	"net/http"
+	"time"
+
 	"github.com/gin-gonic/gin"
 
 	common "jabberwocky238/combinator/core/common"
 )
 
+// startTimeout 实例启动时连通性检查的超时
+const startTimeout = 10 * time.Second
+
 type KVGateway struct {
-	grg    *gin.RouterGroup
-	KvConf []common.KVConfig
-	KvMap  map[string]common.KV
+	grg      *gin.RouterGroup
+	reg      *common.Registry[common.KVConfig, common.KV]
+	initConf []common.KVConfig
 }
 
 func NewGateway(grg *gin.RouterGroup, conf []common.KVConfig) *KVGateway {
 	return &KVGateway{
-		grg:    grg,
-		KvConf: conf,
-		KvMap:  make(map[string]common.KV),
+		grg:      grg,
+		reg:      common.NewRegistry("kv", newKV),
+		initConf: conf,
 	}
 }
 
-func (gw *KVGateway) loadKVs() error {
-	for _, kvConf := range gw.KvConf {
-		parsed, err := ParseKVURL(kvConf.URL)
-		if err != nil {
-			common.Logger.Errorf("Failed to parse KV URL for %s: %v", kvConf.ID, err)
-			return err
-		}
-
-		// Use factory to create KV instance
-		kv, err := CreateKV(parsed)
-		if err != nil {
-			common.Logger.Errorf("Failed to create KV %s: %v", kvConf.ID, err)
-			return err
-		}
-
-		gw.KvMap[kvConf.ID] = kv
-
-		if err = kv.Start(); err != nil {
-			common.Logger.Errorf("Failed to start KV %s: %v", kvConf.ID, err)
-			return err
-		}
-		common.Logger.Infof("Loaded %s KV: %s", parsed.Type, kvConf.ID)
-	}
-	return nil
-}
-
 func (gw *KVGateway) Start() error {
 	// KV 路由组
 	gw.grg.Use(gw.middlewareKV())
@@ -54,7 +35,7 @@ func (gw *KVGateway) Start() error {
 		gw.grg.POST("/set", gw.handleSet)
 	}
 
-	return gw.Reload(gw.KvConf)
+	return gw.Reload(gw.initConf)
 }
 
 func (gw *KVGateway) middlewareKV() gin.HandlerFunc {
@@ -81,17 +62,16 @@ func (gw *KVGateway) middlewareKV() gin.HandlerFunc {
 }
 
 func (gw *KVGateway) handleGet(c *gin.Context) {
-	kv := gw.KvMap[c.GetString("kv_id")]
-	if kv == nil {
-		c.JSON(400, gin.H{"error": "invalid KV ID"})
+	kv, err := gw.lookup(c)
+	if err != nil {
 		return
 	}
 
 	key := c.GetString("kv_key")
 
-	value, err := kv.Get(key)
+	value, err := kv.Get(c.Request.Context(), key)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		c.JSON(common.ErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -99,96 +79,132 @@ func (gw *KVGateway) handleGet(c *gin.Context) {
 }
 
 func (gw *KVGateway) handleSet(c *gin.Context) {
-	kv := gw.KvMap[c.GetString("kv_id")]
-	if kv == nil {
-		c.JSON(400, gin.H{"error": "invalid KV ID"})
+	kv, err := gw.lookup(c)
+	if err != nil {
 		return
 	}
 
 	key := c.GetString("kv_key")
 
+	if limit := common.MaxValueSizeOf(kv); limit > 0 {
+		// 多读一个字节以便 Set 报告超限，更大的请求体不会整个读入内存
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+1)
+	}
 	value, err := c.GetRawData()
 	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(413, gin.H{"error": common.ErrValueTooLarge.Error()})
+			return
+		}
 		c.JSON(400, gin.H{"error": "failed to read request body"})
 		return
 	}
 
-	if err := kv.Set(key, value); err != nil {
-		common.Logger.Errorf("Set failed: %v", err)
-		c.JSON(500, gin.H{"error": err.Error()})
+	if err := kv.Set(c.Request.Context(), key, value); err != nil {
+		common.Log(c.Request.Context()).Errorf("Set failed: %v", err)
+		c.JSON(common.ErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.String(200, "OK")
 }
 
-// Reload 重新加载 KV 配置
-func (gw *KVGateway) Reload(newConf []common.KVConfig) error {
-	// 构建新配置的 ID 集合
-	newIDs := make(map[string]common.KVConfig)
-	for _, conf := range newConf {
-		newIDs[conf.ID] = conf
-	}
-
-	// 创建新的 KV map
-	newKVMap := make(map[string]common.KV)
-
-	// 1. 保留未变化的 KV
-	for id, kv := range gw.KvMap {
-		if newConf, exists := newIDs[id]; exists {
-			// 检查配置是否变化
-			oldConf := gw.findConfigByID(id)
-			if oldConf != nil && oldConf.URL == newConf.URL {
-				// 配置未变化，保留
-				newKVMap[id] = kv
-				common.Logger.Infof("KV %s unchanged, keeping connection", id)
-				delete(newIDs, id)
-				continue
-			}
-		}
-		// 配置变化或被删除，关闭旧连接
-		if err := kv.Close(); err != nil {
-			common.Logger.Warnf("Failed to close KV %s: %v", id, err)
+// lookup 取出请求对应的实例，失败时写入 400（未配置）或 503（不可用）
+func (gw *KVGateway) lookup(c *gin.Context) (common.KV, error) {
+	kv, err := gw.reg.Lookup(c.GetString("kv_id"))
+	if err != nil {
+		status := common.LookupStatus(err)
+		if status == 400 {
+			c.JSON(400, gin.H{"error": "invalid KV ID"})
+		} else {
+			c.JSON(status, gin.H{"error": err.Error()})
 		}
-		common.Logger.Infof("Closed KV %s", id)
+		return nil, err
 	}
+	return kv, nil
+}
 
-	// 2. 加载新增或变化的 KV
-	for id, conf := range newIDs {
-		parsed, err := ParseKVURL(conf.URL)
-		if err != nil {
-			common.Logger.Errorf("Failed to parse KV URL for %s: %v", id, err)
-			return err
-		}
+// ReloadPlan 两阶段重载中已准备好的 KV 实例
+type ReloadPlan = common.RegistryPlan[common.KVConfig, common.KV]
 
-		kv, err := CreateKV(parsed)
-		if err != nil {
-			common.Logger.Errorf("Failed to create KV %s: %v", id, err)
-			return err
-		}
+func newKV(conf common.KVConfig) (common.KV, error) {
+	parsed, opts, err := ParseOptions(conf)
+	if err != nil {
+		return nil, err
+	}
 
-		if err = kv.Start(); err != nil {
-			common.Logger.Errorf("Failed to start KV %s: %v", id, err)
-			return err
-		}
+	// Use factory to create KV instance
+	kv, err := CreateKV(parsed, opts)
+	if err != nil {
+		return nil, err
+	}
 
-		newKVMap[id] = kv
-		common.Logger.Infof("Loaded %s KV: %s", parsed.Type, id)
+	if err = kv.Start(); err != nil {
+		return nil, err
 	}
+	return kv, nil
+}
 
-	// 3. 更新配置和 map
-	gw.KvMap = newKVMap
-	gw.KvConf = newConf
+// Open 创建并启动一个独立于网关的实例，用于 config validate --connect，调用方负责 Close
+func Open(conf common.KVConfig) (common.KV, error) {
+	return newKV(conf)
+}
 
-	return nil
+// Prepare 第一阶段：启动所有新增或变化的实例，不影响正在服务的实例
+func (gw *KVGateway) Prepare(newConf []common.KVConfig) (*ReloadPlan, error) {
+	return gw.reg.Prepare(newConf)
 }
 
-// findConfigByID 查找配置
-func (gw *KVGateway) findConfigByID(id string) *common.KVConfig {
-	for _, conf := range gw.KvConf {
-		if conf.ID == id {
-			return &conf
-		}
-	}
-	return nil
+// Diff 计算新配置中每个实例的变化，不启动实例
+func (gw *KVGateway) Diff(newConf []common.KVConfig) []common.InstanceResult {
+	return gw.reg.Diff(newConf)
+}
+
+// Commit 第二阶段：替换实例表并关闭被替换或删除的旧实例
+func (gw *KVGateway) Commit(plan *ReloadPlan) error {
+	return gw.reg.Commit(plan)
+}
+
+// Reload 加载配置，启动失败的实例登记为不可用并在后台重试；
+// 运行中的重载使用 Prepare / Commit
+func (gw *KVGateway) Reload(newConf []common.KVConfig) error {
+	return gw.reg.Load(newConf)
+}
+
+// SetBreaker 为之后启动的每个实例套上熔断器，参数由 policy 共享，需在 Start 之前调用
+func (gw *KVGateway) SetBreaker(policy *common.BreakerPolicy) {
+	gw.reg.SetWrapper(func(id string, kv common.KV) common.KV {
+		return newBreakerKV(kv, common.NewBreaker("kv", id, policy, nil))
+	})
+}
+
+// Close 关闭所有 KV 实例，返回所有关闭失败
+func (gw *KVGateway) Close() error {
+	return gw.reg.Close()
+}
+
+// Get 返回指定 ID 的可用实例
+func (gw *KVGateway) Get(id string) (common.KV, bool) {
+	return gw.reg.Get(id)
+}
+
+// Has 配置中是否有该 ID 的实例，启动失败、正在后台重试的实例也算
+func (gw *KVGateway) Has(id string) bool {
+	return gw.reg.Has(id)
+}
+
+// Count 当前可用的实例数
+func (gw *KVGateway) Count() int {
+	return gw.reg.Count()
+}
+
+// Unavailable 返回启动失败、正在后台重试的实例
+func (gw *KVGateway) Unavailable() []*common.UnavailableError {
+	return gw.reg.Unavailable()
+}
+
+// Range 按配置顺序遍历当前所有可用的 KV 实例
+func (gw *KVGateway) Range(fn func(id string, kv common.KV) bool) {
+	gw.reg.Range(fn)
 }
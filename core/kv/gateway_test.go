@@ -0,0 +1,66 @@
+package kv
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+// 使用 -race 运行：请求处理与 reload 并发时不应出现数据竞争
+func TestGatewayConcurrentReload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	gw := NewGateway(r.Group("/kv"), []common.KVConfig{{ID: "main", URL: "memory://"}})
+	if err := gw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer gw.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 16)
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				req := httptest.NewRequest(http.MethodPost, "/kv/set", bytes.NewBufferString("v"))
+				req.Header.Set("X-Combinator-KV-ID", "main")
+				req.Header.Set("X-Combinator-KV-Key", fmt.Sprintf("k%d", w))
+				rec := httptest.NewRecorder()
+				r.ServeHTTP(rec, req)
+				if rec.Code != http.StatusOK {
+					select {
+					case errs <- fmt.Sprintf("set: %d %s", rec.Code, rec.Body.String()):
+					default:
+					}
+					return
+				}
+			}
+		}(w)
+	}
+
+	for i := 0; i < 100; i++ {
+		confs := []common.KVConfig{
+			{ID: "main", URL: "memory://"},
+			{ID: fmt.Sprintf("extra%d", i%4), URL: "memory://"},
+		}
+		if err := gw.Reload(confs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for e := range errs {
+		t.Error(e)
+	}
+	if gw.Count() != 2 {
+		t.Errorf("got %d instances, want 2", gw.Count())
+	}
+}
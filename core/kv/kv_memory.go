@@ -1,56 +1,96 @@
 package kv
 
 import (
+	"context"
 	"fmt"
 	common "jabberwocky238/combinator/core/common"
 	"sync"
+	"time"
 )
 
 func init() {
-	RegisterKVFactory("memory", func(parsed *ParsedKVURL) (common.KV, error) {
-		return NewMemoryKV(), nil
+	RegisterKVFactory("memory", func(parsed *ParsedKVURL, opts *Options) (common.KV, error) {
+		return NewMemoryKV(opts.TTL()), nil
 	})
 }
 
+type memoryEntry struct {
+	value   []byte
+	expires time.Time // 零值表示不过期
+}
+
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
 type MemoryKV struct {
-	store map[string][]byte
-	mu    sync.RWMutex
+	store     map[string]memoryEntry
+	mu        sync.RWMutex
+	ttl       time.Duration
+	lastSweep time.Time
 }
 
-func NewMemoryKV() *MemoryKV {
+// NewMemoryKV ttl 为 0 时 key 不过期
+func NewMemoryKV(ttl time.Duration) *MemoryKV {
 	return &MemoryKV{
-		store: make(map[string][]byte),
+		store:     make(map[string]memoryEntry),
+		ttl:       ttl,
+		lastSweep: time.Now(),
 	}
 }
 
 // Get retrieves a value by key
-func (m *MemoryKV) Get(key string) ([]byte, error) {
+func (m *MemoryKV) Get(ctx context.Context, key string) ([]byte, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	value, ok := m.store[key]
-	if !ok {
+	entry, ok := m.store[key]
+	if !ok || entry.expired(time.Now()) {
 		return nil, fmt.Errorf("key not found: %s", key)
 	}
 
 	// Return a copy to prevent external modification
-	result := make([]byte, len(value))
-	copy(result, value)
+	result := make([]byte, len(entry.value))
+	copy(result, entry.value)
 	return result, nil
 }
 
 // Set stores a value by key
-func (m *MemoryKV) Set(key string, value []byte) error {
+func (m *MemoryKV) Set(ctx context.Context, key string, value []byte) error {
+	return m.SetTTL(ctx, key, value, m.ttl)
+}
+
+// SetTTL stores a value that expires after ttl, 0 means never
+func (m *MemoryKV) SetTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	// Store a copy to prevent external modification
 	valueCopy := make([]byte, len(value))
 	copy(valueCopy, value)
-	m.store[key] = valueCopy
+	entry := memoryEntry{value: valueCopy}
+	if ttl > 0 {
+		now := time.Now()
+		entry.expires = now.Add(ttl)
+		m.sweep(now, ttl)
+	}
+	m.store[key] = entry
 	return nil
 }
 
+// sweep 每隔一个 ttl（至少为实例的 defaultTTL）清理一次过期 key，调用方持有写锁
+func (m *MemoryKV) sweep(now time.Time, ttl time.Duration) {
+	if now.Sub(m.lastSweep) < max(ttl, m.ttl) {
+		return
+	}
+	m.lastSweep = now
+	for k, e := range m.store {
+		if e.expired(now) {
+			delete(m.store, k)
+		}
+	}
+}
+
 // Start initializes the memory KV store (no-op)
 func (m *MemoryKV) Start() error {
 	return nil
@@ -61,6 +101,11 @@ func (m *MemoryKV) Close() error {
 	return nil
 }
 
+// Ping always succeeds for the in-memory store
+func (m *MemoryKV) Ping(ctx context.Context) error {
+	return nil
+}
+
 // Type returns the KV store type
 func (m *MemoryKV) Type() string {
 	return "memory"
@@ -3,14 +3,17 @@ package kv
 import (
 	"context"
 	"fmt"
+	"time"
+
 	common "jabberwocky238/combinator/core/common"
+	trace "jabberwocky238/combinator/core/trace"
 
 	"github.com/redis/go-redis/v9"
 )
 
 func init() {
-	RegisterKVFactory("redis", func(parsed *ParsedKVURL) (common.KV, error) {
-		return NewRedisKV(parsed.Host, parsed.Port, parsed.Password, parsed.DB), nil
+	RegisterKVFactory("redis", func(parsed *ParsedKVURL, opts *Options) (common.KV, error) {
+		return NewRedisKV(parsed.Host, parsed.Port, parsed.Password, parsed.DB, opts), nil
 	})
 }
 
@@ -20,47 +23,97 @@ type RedisKV struct {
 	port     int
 	password string
 	db       int
-	ctx      context.Context
+	opts     *Options
 }
 
-func NewRedisKV(host string, port int, password string, db int) *RedisKV {
+func NewRedisKV(host string, port int, password string, db int, opts *Options) *RedisKV {
+	if opts == nil {
+		opts = &Options{}
+	}
 	return &RedisKV{
 		host:     host,
 		port:     port,
 		password: password,
 		db:       db,
-		ctx:      context.Background(),
+		opts:     opts,
 	}
 }
 
 // Get retrieves a value by key
-func (r *RedisKV) Get(key string) ([]byte, error) {
-	val, err := r.client.Get(r.ctx, key).Result()
+func (r *RedisKV) Get(ctx context.Context, key string) ([]byte, error) {
+	ctx, span := r.startSpan(ctx, "redis.get")
+	defer span.Finish()
+
+	val, err := r.client.Get(ctx, key).Result()
 	if err == redis.Nil {
 		return nil, fmt.Errorf("key not found: %s", key)
 	}
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 	return []byte(val), nil
 }
 
 // Set stores a value by key
-func (r *RedisKV) Set(key string, value []byte) error {
-	return r.client.Set(r.ctx, key, value, 0).Err()
+func (r *RedisKV) Set(ctx context.Context, key string, value []byte) error {
+	ctx, span := r.startSpan(ctx, "redis.set")
+	defer span.Finish()
+
+	err := r.client.Set(ctx, key, value, r.opts.TTL()).Err()
+	span.RecordError(err)
+	return err
+}
+
+// SetTTL stores a value that expires after ttl instead of the instance defaultTTL
+func (r *RedisKV) SetTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ctx, span := r.startSpan(ctx, "redis.set")
+	defer span.Finish()
+
+	err := r.client.Set(ctx, key, value, ttl).Err()
+	span.RecordError(err)
+	return err
+}
+
+func (r *RedisKV) startSpan(ctx context.Context, name string) (context.Context, *trace.Span) {
+	ctx, span := trace.Start(ctx, name, trace.SpanKindClient)
+	span.SetAttr("db.system", "redis")
+	span.SetAttr("server.address", fmt.Sprintf("%s:%d", r.host, r.port))
+	span.SetAttr("db.redis.database_index", r.db)
+	return ctx, span
 }
 
 // Start initializes the Redis connection
 func (r *RedisKV) Start() error {
 	r.client = redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", r.host, r.port),
-		Password: r.password,
-		DB:       r.db,
+		Addr:         fmt.Sprintf("%s:%d", r.host, r.port),
+		Password:     r.password,
+		DB:           r.db,
+		PoolSize:     r.opts.PoolSize,
+		MinIdleConns: r.opts.MinIdleConns,
+		DialTimeout:  time.Duration(r.opts.DialTimeout) * time.Millisecond,
+		ReadTimeout:  time.Duration(r.opts.ReadTimeout) * time.Millisecond,
+		WriteTimeout: time.Duration(r.opts.WriteTimeout) * time.Millisecond,
 	})
 
 	// Test connection
-	_, err := r.client.Ping(r.ctx).Result()
-	return err
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	defer cancel()
+	if _, err := r.client.Ping(ctx).Result(); err != nil {
+		// 失败后 registry 会在后台重试 Start，关闭本次的连接池，避免每次重试泄漏一个
+		r.client.Close()
+		r.client = nil
+		return err
+	}
+	return nil
+}
+
+// Ping sends a Redis PING
+func (r *RedisKV) Ping(ctx context.Context) error {
+	if r.client == nil {
+		return fmt.Errorf("redis client not started")
+	}
+	return r.client.Ping(ctx).Err()
 }
 
 func (r *RedisKV) Close() error {
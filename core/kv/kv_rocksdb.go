@@ -4,6 +4,7 @@
 package kv
 
 import (
+	"context"
 	"fmt"
 
 	common "jabberwocky238/combinator/core/common"
@@ -12,7 +13,7 @@ import (
 )
 
 func init() {
-	RegisterKVFactory("rocksdb", func(parsed *ParsedKVURL) (common.KV, error) {
+	RegisterKVFactory("rocksdb", func(parsed *ParsedKVURL, opts *Options) (common.KV, error) {
 		return NewRocksDBKV(parsed.Path), nil
 	})
 }
@@ -32,7 +33,7 @@ func NewRocksDBKV(path string) *RocksDBKV {
 }
 
 // Get retrieves a value by key
-func (r *RocksDBKV) Get(key string) ([]byte, error) {
+func (r *RocksDBKV) Get(ctx context.Context, key string) ([]byte, error) {
 	value, err := r.db.GetBytes(r.ro, []byte(key))
 	if err != nil {
 		return nil, err
@@ -44,7 +45,7 @@ func (r *RocksDBKV) Get(key string) ([]byte, error) {
 }
 
 // Set stores a value by key
-func (r *RocksDBKV) Set(key string, value []byte) error {
+func (r *RocksDBKV) Set(ctx context.Context, key string, value []byte) error {
 	return r.db.Put(r.wo, []byte(key), value)
 }
 
@@ -65,6 +66,14 @@ func (r *RocksDBKV) Start() error {
 	return nil
 }
 
+// Ping reports whether the database is open; RocksDB is embedded so there is no connection to check
+func (r *RocksDBKV) Ping(ctx context.Context) error {
+	if r.db == nil {
+		return fmt.Errorf("rocksdb not started")
+	}
+	return nil
+}
+
 func (r *RocksDBKV) Close() error {
 	if r.db != nil {
 		r.db.Close()
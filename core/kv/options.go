@@ -0,0 +1,137 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+// Options 从 KVConfig.Metadata 解析的实例选项，例如
+// {"keyPrefix": "app:", "maxValueSize": 1048576, "defaultTTL": 3600, "poolSize": 20}
+type Options struct {
+	KeyPrefix    string `json:"keyPrefix,omitempty"`    // 所有 key 自动加上前缀
+	MaxValueSize int    `json:"maxValueSize,omitempty"` // 字节，0 表示不限制
+	DefaultTTL   int    `json:"defaultTTL,omitempty"`   // 秒，0 表示不过期；rocksdb 不支持
+	// Mode 为 read-write 或 read-only，KV 不支持 append-only；ReadOnly 为 true 等同于 read-only
+	Mode     common.AccessMode `json:"mode,omitempty"`
+	ReadOnly bool              `json:"readOnly,omitempty"`
+
+	// 以下仅 redis
+	PoolSize     int `json:"poolSize,omitempty"`
+	MinIdleConns int `json:"minIdleConns,omitempty"`
+	DialTimeout  int `json:"dialTimeout,omitempty"`  // 毫秒
+	ReadTimeout  int `json:"readTimeout,omitempty"`  // 毫秒
+	WriteTimeout int `json:"writeTimeout,omitempty"` // 毫秒
+}
+
+// TTL 返回 DefaultTTL 对应的时长
+func (o *Options) TTL() time.Duration {
+	return time.Duration(o.DefaultTTL) * time.Second
+}
+
+func (o *Options) validate(kvType string) error {
+	if o.MaxValueSize < 0 || o.DefaultTTL < 0 || o.PoolSize < 0 || o.MinIdleConns < 0 ||
+		o.DialTimeout < 0 || o.ReadTimeout < 0 || o.WriteTimeout < 0 {
+		return errors.New("metadata: values must not be negative")
+	}
+	if kvType != "redis" && (o.PoolSize > 0 || o.MinIdleConns > 0 || o.DialTimeout > 0 || o.ReadTimeout > 0 || o.WriteTimeout > 0) {
+		return fmt.Errorf("metadata: pool and timeout settings are only supported by redis, not %s", kvType)
+	}
+	if kvType == "rocksdb" && o.DefaultTTL > 0 {
+		return errors.New("metadata: defaultTTL is not supported by rocksdb")
+	}
+	mode, err := common.ResolveAccessMode(o.Mode, o.ReadOnly)
+	if err != nil {
+		return err
+	}
+	if mode == common.ModeAppendOnly {
+		return errors.New("metadata: append-only mode is only supported by RDB")
+	}
+	o.Mode, o.ReadOnly = mode, mode == common.ModeReadOnly
+	return nil
+}
+
+// ParseOptions 解析并校验实例选项，Metadata 为空时返回零值
+func ParseOptions(conf common.KVConfig) (*ParsedKVURL, *Options, error) {
+	parsed, err := ParseKVURL(conf.URL)
+	if err != nil {
+		return nil, nil, common.InvalidURL(err)
+	}
+	opts := &Options{}
+	if err := common.DecodeMetadata(conf.Metadata, opts); err != nil {
+		return nil, nil, err
+	}
+	if err := opts.validate(parsed.Type); err != nil {
+		return nil, nil, err
+	}
+	return parsed, opts, nil
+}
+
+// Check 校验单个实例的 URL、选项以及该类型是否已注册，不启动实例；返回后端类型
+func Check(conf common.KVConfig) (string, error) {
+	parsed, _, err := ParseOptions(conf)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := kvFactories[parsed.Type]; !ok {
+		if parsed.Type == "rocksdb" {
+			return parsed.Type, errors.New("rocksdb support is not compiled in, rebuild with -tags kv_rocksdb")
+		}
+		return parsed.Type, fmt.Errorf("unsupported KV type: %s", parsed.Type)
+	}
+	return parsed.Type, nil
+}
+
+// Validate 校验所有实例的 URL 与选项，用于在启动实例之前发现配置错误
+func Validate(confs []common.KVConfig) error {
+	var errs []error
+	for _, conf := range confs {
+		if _, err := Check(conf); err != nil {
+			errs = append(errs, fmt.Errorf("KV %s: %w", conf.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// optionsKV 实现与后端无关的选项：key 前缀、值大小限制与只读
+type optionsKV struct {
+	common.KV
+	opts *Options
+}
+
+func (k *optionsKV) MaxValueSize() int64 { return int64(k.opts.MaxValueSize) }
+
+func (k *optionsKV) Get(ctx context.Context, key string) ([]byte, error) {
+	return k.KV.Get(ctx, k.opts.KeyPrefix+key)
+}
+
+func (k *optionsKV) Set(ctx context.Context, key string, value []byte) error {
+	if err := k.checkWrite(value); err != nil {
+		return err
+	}
+	return k.KV.Set(ctx, k.opts.KeyPrefix+key, value)
+}
+
+func (k *optionsKV) SetTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	e, ok := k.KV.(common.ExpiringKV)
+	if !ok {
+		return common.ErrTTLUnsupported
+	}
+	if err := k.checkWrite(value); err != nil {
+		return err
+	}
+	return e.SetTTL(ctx, k.opts.KeyPrefix+key, value, ttl)
+}
+
+func (k *optionsKV) checkWrite(value []byte) error {
+	if k.opts.ReadOnly {
+		return common.ErrReadOnly
+	}
+	if k.opts.MaxValueSize > 0 && len(value) > k.opts.MaxValueSize {
+		return fmt.Errorf("%w: %d > %d bytes", common.ErrValueTooLarge, len(value), k.opts.MaxValueSize)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+func TestOptions(t *testing.T) {
+	ctx := context.Background()
+	kv, err := newKV(common.KVConfig{ID: "a", URL: "memory://", Metadata: map[string]any{
+		"keyPrefix":    "app:",
+		"maxValueSize": 4,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.Set(ctx, "k", []byte("12345")); !errors.Is(err, common.ErrValueTooLarge) || common.ErrorStatus(err) != 413 {
+		t.Fatalf("want ErrValueTooLarge, got %v", err)
+	}
+	if err := kv.Set(ctx, "k", []byte("1234")); err != nil {
+		t.Fatal(err)
+	}
+	// 网关按包装后的实例读取上限，不再重新解析配置
+	if n := common.MaxValueSizeOf(newBreakerKV(kv, nil)); n != 4 {
+		t.Fatalf("MaxValueSizeOf: got %d", n)
+	}
+	inner := kv.(*optionsKV).KV
+	if v, err := inner.Get(ctx, "app:k"); err != nil || string(v) != "1234" {
+		t.Fatalf("key prefix not applied: %q %v", v, err)
+	}
+
+	ro, _ := newKV(common.KVConfig{ID: "b", URL: "memory://", Metadata: map[string]any{"readOnly": true}})
+	if err := ro.Set(ctx, "k", nil); !errors.Is(err, common.ErrReadOnly) {
+		t.Fatalf("want ErrReadOnly, got %v", err)
+	}
+
+	ttl := NewMemoryKV(10 * time.Millisecond)
+	_ = ttl.Set(ctx, "k", []byte("v"))
+	time.Sleep(20 * time.Millisecond)
+	if _, err := ttl.Get(ctx, "k"); err == nil {
+		t.Error("key did not expire")
+	}
+
+	for _, md := range []any{
+		map[string]any{"poolSize": 10},
+		map[string]any{"unknown": 1},
+		map[string]any{"defaultTTL": -1},
+	} {
+		if err := Validate([]common.KVConfig{{ID: "m", URL: "memory://", Metadata: md}}); err == nil {
+			t.Errorf("metadata %v: expected validation error", md)
+		}
+	}
+}
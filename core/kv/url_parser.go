@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"net/url"
 	"strconv"
+
+	common "jabberwocky238/combinator/core/common"
 )
 
 // ParsedKVURL contains parsed KV store connection information
@@ -22,7 +24,7 @@ type ParsedKVURL struct {
 //   - rocksdb:///path/to/db
 //   - memory://
 func ParseKVURL(rawURL string) (*ParsedKVURL, error) {
-	u, err := url.Parse(rawURL)
+	u, err := common.ParseURL(rawURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid URL: %w", err)
 	}
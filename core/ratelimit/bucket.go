@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+// bucketState 令牌桶状态
+type bucketState struct {
+	Tokens float64 `json:"t"`
+	Last   int64   `json:"ts"` // unix nano
+}
+
+// take 按经过的时间补充令牌并尝试取走一个，返回需要等待的时间
+func (b *bucketState) take(now time.Time, rate float64, burst int) (bool, time.Duration) {
+	if b.Last == 0 {
+		b.Tokens = float64(burst)
+	} else if elapsed := now.Sub(time.Unix(0, b.Last)).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(float64(burst), b.Tokens+elapsed*rate)
+	}
+	b.Last = now.UnixNano()
+
+	if b.Tokens >= 1 {
+		b.Tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.Tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+// Store 保存令牌桶状态
+type Store interface {
+	Take(ctx context.Context, key string, rate float64, burst int) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// MemoryStore 进程内令牌桶，空闲的桶会被定期清理
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucketState
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+const sweepInterval = time.Minute
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucketState),
+		now:     time.Now,
+	}
+}
+
+func (m *MemoryStore) Take(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.Sub(m.lastSweep) > sweepInterval {
+		m.sweep(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucketState{}
+		m.buckets[key] = b
+	}
+	allowed, wait := b.take(now, rate, burst)
+	return allowed, wait, nil
+}
+
+// sweep 删除一个清理周期内未使用的桶（此时它们早已补满）
+func (m *MemoryStore) sweep(now time.Time) {
+	cutoff := now.Add(-sweepInterval).UnixNano()
+	for k, b := range m.buckets {
+		if b.Last < cutoff {
+			delete(m.buckets, k)
+		}
+	}
+	m.lastSweep = now
+}
+
+// KVStore 把令牌桶保存在一个 KV 实例中，使多个网关副本共享限流状态
+// KV 接口没有原子操作，并发下为尽力而为：短时间内可能略微超出限额
+type KVStore struct {
+	kv       func() common.KV
+	fallback *MemoryStore
+	now      func() time.Time
+}
+
+const kvKeyPrefix = "combinator:ratelimit:"
+
+// NewKVStore 每次请求通过 kv 获取当前实例，以便配置重载后仍然可用
+// KV 不可用时退回到进程内限流
+func NewKVStore(kv func() common.KV, fallback *MemoryStore) *KVStore {
+	return &KVStore{kv: kv, fallback: fallback, now: time.Now}
+}
+
+func (s *KVStore) Take(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	kv := s.kv()
+	if kv == nil {
+		allowed, wait, _ := s.fallback.Take(ctx, key, rate, burst)
+		return allowed, wait, fmt.Errorf("rate limit store unavailable")
+	}
+
+	var b bucketState
+	// 读取失败（包括 key 不存在）时视为新桶
+	if raw, err := kv.Get(ctx, kvKeyPrefix+key); err == nil {
+		if err := json.Unmarshal(raw, &b); err != nil {
+			b = bucketState{}
+		}
+	}
+
+	allowed, wait := b.take(s.now(), rate, burst)
+	raw, _ := json.Marshal(b)
+	if err := setTTL(ctx, kv, kvKeyPrefix+key, raw, b.refill(rate, burst)); err != nil {
+		return allowed, wait, fmt.Errorf("failed to save rate limit state: %w", err)
+	}
+	return allowed, wait, nil
+}
+
+// refill 桶补满所需的时间；补满的桶与不存在的桶等价，之后 key 可以过期
+func (b *bucketState) refill(rate float64, burst int) time.Duration {
+	secs := math.Ceil((float64(burst) - b.Tokens) / rate)
+	return time.Duration(max(secs, 1)) * time.Second
+}
+
+// setTTL 状态必须带过期时间写入，否则每个客户端都会在 KV 中永久留下一个 key
+func setTTL(ctx context.Context, kv common.KV, key string, value []byte, ttl time.Duration) error {
+	e, ok := kv.(common.ExpiringKV)
+	if !ok {
+		return common.ErrTTLUnsupported
+	}
+	return e.SetTTL(ctx, key, value, ttl)
+}
@@ -0,0 +1,118 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	auth "jabberwocky238/combinator/core/auth"
+	common "jabberwocky238/combinator/core/common"
+)
+
+// Request 描述一次需要限流判断的请求
+type Request struct {
+	Kind     string
+	ID       string
+	Op       string
+	APIKey   string // 认证后的调用方名称，未认证时为空
+	ClientIP string
+}
+
+type rule struct {
+	name   string
+	key    string
+	target auth.Scope
+	rate   float64
+	burst  int
+}
+
+// Limiter 按规则依次检查令牌桶，任一规则拒绝即拒绝
+type Limiter struct {
+	rules []rule
+	store Store
+}
+
+// New builds a Limiter from config; nil config or no rules disables rate limiting
+func New(conf *common.RateLimitConfig, store Store) (*Limiter, error) {
+	if conf == nil || len(conf.Rules) == 0 {
+		return nil, nil
+	}
+
+	l := &Limiter{store: store}
+	for i, r := range conf.Rules {
+		name := r.Name
+		if name == "" {
+			name = "rule" + strconv.Itoa(i)
+		}
+		switch r.Key {
+		case "apikey", "ip", "instance":
+		default:
+			return nil, fmt.Errorf("rate limit %s: unknown key %q, expected apikey, ip or instance", name, r.Key)
+		}
+		if r.Rate <= 0 {
+			return nil, fmt.Errorf("rate limit %s: rate must be positive", name)
+		}
+
+		targetRaw := r.Target
+		if targetRaw == "" {
+			targetRaw = "*"
+		}
+		target, err := auth.ParseScope(targetRaw)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit %s: %w", name, err)
+		}
+
+		burst := r.Burst
+		if burst <= 0 {
+			burst = int(math.Max(1, math.Ceil(r.Rate)))
+		}
+		l.rules = append(l.rules, rule{
+			name:   name,
+			key:    r.Key,
+			target: target,
+			rate:   r.Rate,
+			burst:  burst,
+		})
+	}
+	return l, nil
+}
+
+// Decision 限流结果，RetryAfter 仅在拒绝时有意义
+type Decision struct {
+	Allowed    bool
+	Rule       string
+	RetryAfter time.Duration
+}
+
+// Allow 检查所有命中的规则，存储出错时放行（fail open）
+func (l *Limiter) Allow(ctx context.Context, req Request) Decision {
+	for _, r := range l.rules {
+		if !r.target.Match(req.Kind, req.ID, req.Op) {
+			continue
+		}
+
+		var subject string
+		switch r.key {
+		case "apikey":
+			if req.APIKey == "" {
+				continue
+			}
+			subject = req.APIKey
+		case "ip":
+			subject = req.ClientIP
+		case "instance":
+			subject = req.Kind + ":" + req.ID
+		}
+
+		allowed, wait, err := l.store.Take(ctx, r.name+"|"+subject, r.rate, r.burst)
+		if err != nil {
+			common.Logger.Warnf("Rate limit store error (%s): %v", r.name, err)
+		}
+		if !allowed {
+			return Decision{Allowed: false, Rule: r.name, RetryAfter: wait}
+		}
+	}
+	return Decision{Allowed: true}
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+// TestLimiter 测试令牌桶的突发、补充以及按 target / key 区分桶
+func TestLimiter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	l, err := New(&common.RateLimitConfig{Rules: []common.RateLimitRule{
+		{Name: "app-exec", Key: "instance", Target: "rdb:app:exec", Rate: 2, Burst: 2},
+		{Name: "per-ip", Key: "ip", Rate: 100},
+	}}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	exec := Request{Kind: "rdb", ID: "app", Op: "exec", ClientIP: "10.0.0.1"}
+
+	for i := 0; i < 2; i++ {
+		if d := l.Allow(ctx, exec); !d.Allowed {
+			t.Fatalf("request %d should be allowed within burst", i)
+		}
+	}
+	d := l.Allow(ctx, exec)
+	if d.Allowed || d.Rule != "app-exec" {
+		t.Fatalf("third request should be limited by app-exec, got %+v", d)
+	}
+	if d.RetryAfter != 500*time.Millisecond {
+		t.Errorf("RetryAfter = %v, want 500ms", d.RetryAfter)
+	}
+
+	// 其他实例或操作不受该规则影响
+	if d := l.Allow(ctx, Request{Kind: "rdb", ID: "app", Op: "query", ClientIP: "10.0.0.1"}); !d.Allowed {
+		t.Error("query should not be limited by exec rule")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if d := l.Allow(ctx, exec); !d.Allowed {
+		t.Error("token should be refilled after RetryAfter")
+	}
+
+	if _, err := New(&common.RateLimitConfig{Rules: []common.RateLimitRule{{Key: "user", Rate: 1}}}, store); err == nil {
+		t.Error("unknown key should be rejected")
+	}
+}
@@ -0,0 +1,37 @@
+package rdb
+
+import (
+	"context"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+// breakerRDB 经过熔断器调用底层 RDB，Start / Close / Ping 不经过熔断器
+type breakerRDB struct {
+	common.RDB
+	breaker *common.Breaker
+}
+
+func newBreakerRDB(rdb common.RDB, b *common.Breaker) *breakerRDB {
+	return &breakerRDB{RDB: rdb, breaker: b}
+}
+
+func (r *breakerRDB) Breaker() *common.Breaker { return r.breaker }
+
+func (r *breakerRDB) Query(ctx context.Context, stmt string, args ...any) ([]byte, error) {
+	return common.Call(r.breaker, ctx, func(ctx context.Context) ([]byte, error) {
+		return r.RDB.Query(ctx, stmt, args...)
+	})
+}
+
+func (r *breakerRDB) Exec(ctx context.Context, stmt string, args ...any) error {
+	return r.breaker.Do(ctx, func(ctx context.Context) error {
+		return r.RDB.Exec(ctx, stmt, args...)
+	})
+}
+
+func (r *breakerRDB) Batch(ctx context.Context, stmt []string, args [][]any) error {
+	return r.breaker.Do(ctx, func(ctx context.Context) error {
+		return r.RDB.Batch(ctx, stmt, args)
+	})
+}
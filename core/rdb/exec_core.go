@@ -2,12 +2,17 @@ package rdb
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/csv"
 	"fmt"
 	"strings"
 
 	sqlparser "github.com/jabberwocky238/sqlparser"
+	"github.com/sirupsen/logrus"
+
+	common "jabberwocky238/combinator/core/common"
+	trace "jabberwocky238/combinator/core/trace"
 )
 
 var ebcore = EB.With("core")
@@ -15,6 +20,7 @@ var ebcore = EB.With("core")
 type RDBCore struct {
 	db      *sql.DB
 	rdbType string
+	mode    common.AccessMode // 空值等同于 read-write
 }
 
 func NewRDBCore(db *sql.DB, rdbType string) *RDBCore {
@@ -24,6 +30,11 @@ func NewRDBCore(db *sql.DB, rdbType string) *RDBCore {
 	}
 }
 
+// Stats returns the connection pool statistics of the underlying database
+func (r *RDBCore) Stats() sql.DBStats {
+	return r.db.Stats()
+}
+
 type SQLType string
 
 var (
@@ -33,7 +44,7 @@ var (
 	SQL_TYPE_UNKNOWN SQLType = "OTHER"
 )
 
-func parseStatement(stmt string, rdbType string) (sqlparser.Statement, SQLType, error) {
+func parseStatement(ctx context.Context, stmt string, args []any, rdbType string) (sqlparser.Statement, SQLType, error) {
 	ast, err := sqlparser.Parse(stmt)
 	if err != nil {
 		return nil, SQL_TYPE_UNKNOWN, ebcore.Error("Statement parse failed: %v", err)
@@ -41,7 +52,7 @@ func parseStatement(stmt string, rdbType string) (sqlparser.Statement, SQLType,
 
 	// 新的 sqlparser 返回 AST，包含多个 statements
 	if len(ast.Statements) == 0 {
-		fmt.Printf("[WARN] Statement has no statements in AST\n")
+		return nil, SQL_TYPE_UNKNOWN, ebcore.Error("empty statement")
 	} else if len(ast.Statements) > 1 {
 		return nil, SQL_TYPE_UNKNOWN, ebcore.Error("multiple statements not supported")
 	}
@@ -50,25 +61,14 @@ func parseStatement(stmt string, rdbType string) (sqlparser.Statement, SQLType,
 	node := ast.Statements[0]
 
 	// 记录日志
-	var sqlType SQLType
-	switch node.(type) {
-	case *sqlparser.Select:
-		sqlType = SQL_TYPE_DQL
-	case *sqlparser.Insert, *sqlparser.Update, *sqlparser.Delete:
-		sqlType = SQL_TYPE_DML
-	case *sqlparser.CreateTable,
-		*sqlparser.AlterTable,
-		*sqlparser.DropTable,
-		*sqlparser.CreateIndex,
-		*sqlparser.DropIndex:
-		sqlType = SQL_TYPE_DDL
-	default:
-		sqlType = SQL_TYPE_UNKNOWN
-	}
+	sqlType := classifyStatement(node)
 
 	// 根据数据库类型应用 shim
 	var transformedNode sqlparser.Statement = node
 	if sqlType == SQL_TYPE_DDL {
+		_, span := trace.Start(ctx, "rdb.shim")
+		span.SetAttr("db.system", rdbType)
+		defer span.Finish()
 		switch rdbType {
 		case "postgres":
 			transformedNode = ddlShimPostgres(node)
@@ -79,25 +79,101 @@ func parseStatement(stmt string, rdbType string) (sqlparser.Statement, SQLType,
 		}
 	}
 
-	fmt.Printf("[INFO] Statement: %s - %s\n", sqlType, transformedNode.String())
+	logStatement(ctx, sqlType, transformedNode, args)
 	return transformedNode, sqlType, nil
 }
 
+// logStatement 只在 debug 级别输出 SQL 文本，参数只记录类型，避免把数据写进日志
+func logStatement(ctx context.Context, sqlType SQLType, node sqlparser.Statement, args []any) {
+	log := common.Log(ctx)
+	if !log.Logger.IsLevelEnabled(logrus.DebugLevel) {
+		return
+	}
+	log.WithFields(logrus.Fields{
+		"sql_type": sqlType,
+		"sql":      node.String(),
+		"args":     redactArgs(args),
+	}).Debug("Statement")
+}
+
+// redactArgs 把每个参数替换为其类型，例如 [string float64 <nil>]
+func redactArgs(args []any) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		out[i] = fmt.Sprintf("%T", arg)
+	}
+	return out
+}
+
+func classifyStatement(node sqlparser.Statement) SQLType {
+	switch node.(type) {
+	case *sqlparser.Select:
+		return SQL_TYPE_DQL
+	case *sqlparser.Insert, *sqlparser.Update, *sqlparser.Delete:
+		return SQL_TYPE_DML
+	case *sqlparser.CreateTable,
+		*sqlparser.AlterTable,
+		*sqlparser.DropTable,
+		*sqlparser.CreateIndex,
+		*sqlparser.DropIndex:
+		return SQL_TYPE_DDL
+	default:
+		return SQL_TYPE_UNKNOWN
+	}
+}
+
+// checkAccess 按实例访问模式检查语句：
+// read-only 只允许 DQL；append-only 另外允许 INSERT，但不允许 ON CONFLICT DO UPDATE
+func (r *RDBCore) checkAccess(node sqlparser.Statement) error {
+	sqlType := classifyStatement(node)
+	switch r.mode {
+	case common.ModeReadOnly:
+		if sqlType != SQL_TYPE_DQL {
+			return fmt.Errorf("%w: %s statements are not allowed", common.ErrReadOnly, sqlType)
+		}
+	case common.ModeAppendOnly:
+		if sqlType == SQL_TYPE_DQL {
+			return nil
+		}
+		insert, ok := node.(*sqlparser.Insert)
+		if !ok {
+			return fmt.Errorf("%w: only INSERT is allowed", common.ErrAppendOnly)
+		}
+		for _, clause := range insert.Upsert {
+			if clause.DoUpdate != nil {
+				return fmt.Errorf("%w: INSERT ... ON CONFLICT DO UPDATE is not allowed", common.ErrAppendOnly)
+			}
+		}
+	}
+	return nil
+}
+
 // 第二步：解析语句（带日志）
-func parseStatements(statements []string, rdbType string) []sqlparser.Statement {
-	nodes := make([]sqlparser.Statement, 0, len(statements))
+// 任何一条无法解析都返回错误，跳过会让之后的 args[i] 对应到错误的语句上
+func parseStatements(ctx context.Context, statements []string, args [][]any, rdbType string) (nodes []sqlparser.Statement, err error) {
+	ctx, span := trace.Start(ctx, "rdb.parse")
+	span.SetAttr("db.statement_count", len(statements))
+	defer func() {
+		span.RecordError(err)
+		span.Finish()
+	}()
+
+	nodes = make([]sqlparser.Statement, 0, len(statements))
 
 	for i, stmt := range statements {
-		node, _, err := parseStatement(stmt, rdbType)
+		var stmtArgs []any
+		if i < len(args) {
+			stmtArgs = args[i]
+		}
+		node, _, err := parseStatement(ctx, stmt, stmtArgs, rdbType)
 		if err != nil {
-			fmt.Printf("[ERROR] Failed to parse statement %d: %v\n", i+1, err)
-			continue
+			return nil, fmt.Errorf("statement %d: %w", i+1, err)
 		}
 
 		nodes = append(nodes, node)
 	}
 
-	return nodes
+	return nodes, nil
 }
 
 // 截断 SQL 用于日志显示
@@ -111,34 +187,42 @@ func truncateSQL(sql string, maxLen int) string {
 }
 
 // 第三步：在事务中执行所有语句
-func executeInTransaction(db *sql.DB, nodes []sqlparser.Statement, args [][]any, rdbType string) error {
+func executeInTransaction(ctx context.Context, db *sql.DB, opts *sql.TxOptions, nodes []sqlparser.Statement, args [][]any, rdbType string) (err error) {
+	ctx, span := trace.Start(ctx, "rdb.execute")
+	span.SetAttr("db.system", rdbType)
+	span.SetAttr("db.statement_count", len(nodes))
+	defer func() {
+		span.RecordError(err)
+		span.Finish()
+	}()
+
 	// 开启事务
-	tx, err := db.Begin()
+	tx, err := db.BeginTx(ctx, opts)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
 	// 执行每条语句
 	for i, node := range nodes {
-		fmt.Printf("[INFO] Executing statement %d\n", i+1)
+		common.Log(ctx).Debugf("Executing statement %d", i+1)
 
 		var err error
 		switch node.(type) {
 		case *sqlparser.Select:
 			// DQL: 查询，输出 CSV（列头 + 数据）
 			stmt := node.String()
-			err = executeQueryToWriter(tx, stmt, args[i])
+			err = executeQueryToWriter(ctx, tx, stmt, args[i])
 		case *sqlparser.Insert, *sqlparser.Update, *sqlparser.Delete:
 			// DML: 修改，输出 JSON（rows_affected, last_insert_id）
 			stmt := node.String()
-			err = executeDMLToWriter(tx, stmt, args[i])
+			err = executeDMLToWriter(ctx, tx, stmt, args[i])
 		case *sqlparser.CreateTable,
 			*sqlparser.AlterTable,
 			*sqlparser.DropTable,
 			*sqlparser.CreateIndex,
 			*sqlparser.DropIndex:
 			// DDL: 定义，传入 node 和 rdbType
-			err = executeDDLToWriter(tx, node, rdbType)
+			err = executeDDLToWriter(ctx, tx, node, rdbType)
 		default:
 			err = fmt.Errorf("unknown SQL type: %T", node)
 		}
@@ -159,8 +243,8 @@ func executeInTransaction(db *sql.DB, nodes []sqlparser.Statement, args [][]any,
 }
 
 // 在事务中执行查询
-func executeQueryToWriter(tx *sql.Tx, stmt string, args []any) error {
-	rows, err := tx.Query(stmt, args...)
+func executeQueryToWriter(ctx context.Context, tx *sql.Tx, stmt string, args []any) error {
+	rows, err := tx.QueryContext(ctx, stmt, args...)
 	if err != nil {
 		return err
 	}
@@ -173,8 +257,8 @@ func executeQueryToWriter(tx *sql.Tx, stmt string, args []any) error {
 }
 
 // 在事务中执行
-func executeDMLToWriter(tx *sql.Tx, stmt string, args []any) error {
-	_, err := tx.Exec(stmt, args...)
+func executeDMLToWriter(ctx context.Context, tx *sql.Tx, stmt string, args []any) error {
+	_, err := tx.ExecContext(ctx, stmt, args...)
 	if err != nil {
 		return err
 	}
@@ -182,7 +266,7 @@ func executeDMLToWriter(tx *sql.Tx, stmt string, args []any) error {
 }
 
 // 在事务中执行 DDL，输出 "OK" 到 writer
-func executeDDLToWriter(tx *sql.Tx, node sqlparser.Statement, rdbType string) error {
+func executeDDLToWriter(ctx context.Context, tx *sql.Tx, node sqlparser.Statement, rdbType string) error {
 	// 根据数据库类型应用 shim
 	var transformedNode sqlparser.Statement
 	switch rdbType {
@@ -198,15 +282,18 @@ func executeDDLToWriter(tx *sql.Tx, node sqlparser.Statement, rdbType string) er
 	stmt := transformedNode.String()
 
 	// 执行 DDL
-	_, err := tx.Exec(stmt)
+	_, err := tx.ExecContext(ctx, stmt)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r *RDBCore) Query(stmt string, args ...any) ([]byte, error) {
-	_, rdbType, err := parseStatement(stmt, r.rdbType)
+func (r *RDBCore) Query(ctx context.Context, stmt string, args ...any) (data []byte, err error) {
+	parseCtx, span := trace.Start(ctx, "rdb.parse")
+	_, rdbType, err := parseStatement(parseCtx, stmt, args, r.rdbType)
+	span.RecordError(err)
+	span.Finish()
 	if err != nil {
 		return nil, err
 	}
@@ -214,7 +301,26 @@ func (r *RDBCore) Query(stmt string, args ...any) ([]byte, error) {
 		return nil, fmt.Errorf("not a DQL statement")
 	}
 
-	rows, err := r.db.Query(stmt, args...)
+	ctx, span = trace.Start(ctx, "rdb.execute")
+	span.SetAttr("db.system", r.rdbType)
+	defer func() {
+		span.RecordError(err)
+		span.Finish()
+	}()
+
+	var rows *sql.Rows
+	if r.mode == common.ModeReadOnly {
+		// 只读实例的查询在只读事务中执行，防止带副作用的函数写入
+		var tx *sql.Tx
+		tx, err = r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+		rows, err = tx.QueryContext(ctx, stmt, args...)
+	} else {
+		rows, err = r.db.QueryContext(ctx, stmt, args...)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -261,29 +367,66 @@ func (r *RDBCore) Query(stmt string, args ...any) ([]byte, error) {
 			return nil, err
 		}
 	}
+	// 遍历中途被取消（超时或客户端断开）时不能把部分结果当作成功返回
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	writer.Flush()
 	return buf.Bytes(), writer.Error()
 }
 
 // Execute executes a DML/DDL statement with optional parameters
-func (r *RDBCore) Exec(stmt string, args ...any) error {
-	_, _, err := parseStatement(stmt, r.rdbType)
+func (r *RDBCore) Exec(ctx context.Context, stmt string, args ...any) (err error) {
+	parseCtx, span := trace.Start(ctx, "rdb.parse")
+	node, _, err := parseStatement(parseCtx, stmt, args, r.rdbType)
+	if err == nil {
+		err = r.checkAccess(node)
+	}
+	span.RecordError(err)
+	span.Finish()
 	if err != nil {
 		return err
 	}
 
-	_, err = r.db.Exec(stmt, args...)
-	if err != nil {
+	ctx, span = trace.Start(ctx, "rdb.execute")
+	span.SetAttr("db.system", r.rdbType)
+	defer func() {
+		span.RecordError(err)
+		span.Finish()
+	}()
+
+	// 执行经过 checkAccess 检查的语句而不是原始文本，与 Batch 相同；
+	// append-only 没有数据库层面的保护，解析结果与原文的任何差异都可能绕过检查
+	stmt = node.String()
+	if r.mode == common.ModeReadOnly {
+		// 与 Query、Batch 相同，在只读事务中执行，防止带副作用的函数写入
+		var tx *sql.Tx
+		tx, err = r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		_, err = tx.ExecContext(ctx, stmt, args...)
 		return err
 	}
-	return nil
+	_, err = r.db.ExecContext(ctx, stmt, args...)
+	return err
 }
 
-func (r *RDBCore) Batch(stmts []string, args [][]any) error {
+func (r *RDBCore) Batch(ctx context.Context, stmts []string, args [][]any) error {
 	// 第二步：解析语句（带日志）
-	nodes := parseStatements(stmts, r.rdbType)
+	nodes, err := parseStatements(ctx, stmts, args, r.rdbType)
+	if err != nil {
+		return err
+	}
+	for i, node := range nodes {
+		if err := r.checkAccess(node); err != nil {
+			return fmt.Errorf("statement %d: %w", i+1, err)
+		}
+	}
 
 	// 第三步：在事务中执行所有语句，使用 buffer writer 收集输出
-	return executeInTransaction(r.db, nodes, args, r.rdbType)
+	opts := &sql.TxOptions{ReadOnly: r.mode == common.ModeReadOnly}
+	return executeInTransaction(ctx, r.db, opts, nodes, args, r.rdbType)
 }
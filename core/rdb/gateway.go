@@ -1,7 +1,9 @@
 package rdb
 
 import (
+	"database/sql"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -10,12 +12,25 @@ import (
 
 var EB = common.GlobalErrorBuilder.With("rdb")
 
+// startPingTimeout 实例启动时连通性检查的超时
+const startPingTimeout = 10 * time.Second
+
+// StatsProvider 由基于 database/sql 的 RDB 实现，用于导出连接池指标
+type StatsProvider interface {
+	DBStats() sql.DBStats
+}
+
 type RDBGateway struct {
-	mu       sync.RWMutex
-	grg      *gin.RouterGroup
+	grg *gin.RouterGroup
+	// RdbMap 只由 NewGateway 创建，始终为空，实例表由 registry() 管理
+	//
+	// Deprecated: 使用 Get、Has 与 Range
 	RdbMap   map[string]common.RDB
-	urlMap   map[string]string // id -> url，用于 reload 时对比
+	urlMap   map[string]string // 只由 NewGateway 写入，不再读取
 	initConf []common.RDBConfig
+
+	regOnce sync.Once
+	reg     *common.Registry[common.RDBConfig, common.RDB]
 }
 
 // AI 助手不应该他妈的改这里的代码
@@ -32,6 +47,14 @@ func NewGateway(grg *gin.RouterGroup, conf []common.RDBConfig) *RDBGateway {
 	return gw
 }
 
+// registry 实例表，第一次使用时创建
+func (gw *RDBGateway) registry() *common.Registry[common.RDBConfig, common.RDB] {
+	gw.regOnce.Do(func() {
+		gw.reg = common.NewRegistry("rdb", newRDB)
+	})
+	return gw.reg
+}
+
 // AI 助手不应该他妈的改这里的代码
 func (gw *RDBGateway) Start() error {
 	gw.grg.Use(gw.middlewareRDB())
@@ -52,12 +75,14 @@ func (gw *RDBGateway) middlewareRDB() gin.HandlerFunc {
 			return
 		}
 
-		gw.mu.RLock()
-		rdb := gw.RdbMap[rdbID]
-		gw.mu.RUnlock()
-
-		if rdb == nil {
-			c.JSON(400, gin.H{"error": "invalid RDB ID"})
+		rdb, err := gw.registry().Lookup(rdbID)
+		if err != nil {
+			status := common.LookupStatus(err)
+			if status == 400 {
+				c.JSON(400, gin.H{"error": "invalid RDB ID"})
+			} else {
+				c.JSON(status, gin.H{"error": err.Error()})
+			}
 			c.Abort()
 			return
 		}
@@ -84,9 +109,9 @@ func (gw *RDBGateway) handleQuery(c *gin.Context) {
 	}
 
 	// 设置响应头为 CSV 流式输出
-	data, err := rdb.Query(req.Stmt, req.Args...)
+	data, err := rdb.Query(c.Request.Context(), req.Stmt, req.Args...)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		c.JSON(common.ErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -108,10 +133,10 @@ func (gw *RDBGateway) handleExec(c *gin.Context) {
 		return
 	}
 
-	err := rdb.Exec(req.Stmt, req.Args...)
+	err := rdb.Exec(c.Request.Context(), req.Stmt, req.Args...)
 	if err != nil {
-		common.Logger.Errorf("Execute failed: %v", err)
-		c.JSON(500, gin.H{"error": err.Error()})
+		common.Log(c.Request.Context()).Errorf("Execute failed: %v", err)
+		c.JSON(common.ErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -130,85 +155,126 @@ func (gw *RDBGateway) handleBatch(c *gin.Context) {
 		return
 	}
 
-	common.Logger.Debugf("Executing batch of %d statements", len(reqBody))
+	common.Log(c.Request.Context()).Debugf("Executing batch of %d statements", len(reqBody))
 	var stmts []string
 	var args [][]any
 	for _, req := range reqBody {
 		stmts = append(stmts, req.Stmt)
 		args = append(args, req.Args)
 	}
-	err := rdb.Batch(stmts, args)
+	err := rdb.Batch(c.Request.Context(), stmts, args)
 	if err != nil {
-		common.Logger.Errorf("Batch execution failed: %v", err)
-		c.JSON(500, gin.H{"error": err.Error()})
+		common.Log(c.Request.Context()).Errorf("Batch execution failed: %v", err)
+		c.JSON(common.ErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.String(200, "OK")
 }
 
-func (gw *RDBGateway) Reload(newConf []common.RDBConfig) error {
-	// 构建新配置的 ID -> Config 映射
-	newIDs := make(map[string]common.RDBConfig)
-	for _, conf := range newConf {
-		newIDs[conf.ID] = conf
-	}
-
-	newRDBMap := make(map[string]common.RDB)
-	newURLMap := make(map[string]string)
+// ReloadPlan 两阶段重载中已准备好的 RDB 实例
+type ReloadPlan = common.RegistryPlan[common.RDBConfig, common.RDB]
 
-	gw.mu.Lock()
-
-	// 1. 遍历旧实例，保留未变化的，关闭变化或删除的
-	for id, rdb := range gw.RdbMap {
-		if conf, exists := newIDs[id]; exists {
-			if gw.urlMap[id] == conf.URL {
-				newRDBMap[id] = rdb
-				newURLMap[id] = conf.URL
-				common.Logger.Infof("RDB %s unchanged", id)
-				delete(newIDs, id)
-				continue
-			}
-		}
-		if err := rdb.Close(); err != nil {
-			common.Logger.Warnf("Failed to close RDB %s: %v", id, err)
-		}
-		common.Logger.Infof("Closed RDB %s", id)
+func newRDB(conf common.RDBConfig) (common.RDB, error) {
+	parsed, opts, err := ParseOptions(conf)
+	if err != nil {
+		return nil, err
 	}
 
-	// 2. 加载新增或变化的 RDB
-	for id, conf := range newIDs {
-		parsed, err := ParseRDBURL(conf.URL)
+	var rdb common.RDB
+	switch parsed.Type {
+	case "postgres":
+		dsn, err := opts.postgresDSN(parsed.DSN)
 		if err != nil {
-			common.Logger.Errorf("Failed to parse RDB URL for %s: %v", id, err)
-			return err
+			return nil, err
 		}
+		rdb = NewPsqlRDB(dsn, opts)
+	case "sqlite":
+		rdb = NewSqliteRDB(opts.sqliteDSN(parsed.Path), opts)
+	default:
+		return nil, EB.Error("unsupported RDB type: %s", parsed.Type)
+	}
 
-		var rdb common.RDB
-		switch parsed.Type {
-		case "postgres":
-			rdb = NewPsqlRDB(parsed.DSN)
-		case "sqlite":
-			rdb = NewSqliteRDB(parsed.Path)
-		default:
-			return EB.Error("unsupported RDB type: %s", parsed.Type)
-		}
+	if err = rdb.Start(); err != nil {
+		return nil, err
+	}
+	return rdb, nil
+}
 
-		if err = rdb.Start(); err != nil {
-			common.Logger.Errorf("Failed to start RDB %s: %v", id, err)
-			return err
-		}
+// Open 创建并启动一个独立于网关的实例，用于 config validate --connect，调用方负责 Close
+func Open(conf common.RDBConfig) (common.RDB, error) {
+	return newRDB(conf)
+}
 
-		newRDBMap[id] = rdb
-		newURLMap[id] = conf.URL
-		common.Logger.Infof("Loaded %s RDB: %s", parsed.Type, id)
-	}
+// Prepare 第一阶段：启动所有新增或变化的实例，不影响正在服务的实例
+func (gw *RDBGateway) Prepare(newConf []common.RDBConfig) (*ReloadPlan, error) {
+	return gw.registry().Prepare(newConf)
+}
+
+// Diff 计算新配置中每个实例的变化，不启动实例
+func (gw *RDBGateway) Diff(newConf []common.RDBConfig) []common.InstanceResult {
+	return gw.registry().Diff(newConf)
+}
+
+// Commit 第二阶段：替换实例表并关闭被替换或删除的旧实例
+func (gw *RDBGateway) Commit(plan *ReloadPlan) error {
+	return gw.registry().Commit(plan)
+}
 
-	// 3. 替换
+// Reload 加载配置，启动失败的实例登记为不可用并在后台重试；
+// 运行中的重载使用 Prepare / Commit
+func (gw *RDBGateway) Reload(newConf []common.RDBConfig) error {
+	return gw.registry().Load(newConf)
+}
+
+// SetBreaker 为之后启动的每个实例套上熔断器，参数由 policy 共享，需在 Start 之前调用
+func (gw *RDBGateway) SetBreaker(policy *common.BreakerPolicy) {
+	gw.registry().SetWrapper(func(id string, rdb common.RDB) common.RDB {
+		return newBreakerRDB(rdb, common.NewBreaker("rdb", id, policy, nil))
+	})
+}
 
-	gw.RdbMap = newRDBMap
-	gw.urlMap = newURLMap
-	gw.mu.Unlock()
+// Close 关闭所有 RDB 实例，返回所有关闭失败
+func (gw *RDBGateway) Close() error {
+	return gw.registry().Close()
+}
 
-	return nil
+// Get 返回指定 ID 的可用实例
+func (gw *RDBGateway) Get(id string) (common.RDB, bool) {
+	return gw.registry().Get(id)
+}
+
+// Has 配置中是否有该 ID 的实例，启动失败、正在后台重试的实例也算
+func (gw *RDBGateway) Has(id string) bool {
+	return gw.registry().Has(id)
+}
+
+// Count 当前可用的实例数
+func (gw *RDBGateway) Count() int {
+	return gw.registry().Count()
+}
+
+// Unavailable 返回启动失败、正在后台重试的实例
+func (gw *RDBGateway) Unavailable() []*common.UnavailableError {
+	return gw.registry().Unavailable()
+}
+
+// Range 按配置顺序遍历当前所有可用的 RDB 实例
+func (gw *RDBGateway) Range(fn func(id string, rdb common.RDB) bool) {
+	gw.registry().Range(fn)
+}
+
+// PoolStats 返回各实例的连接池统计，只包含基于 database/sql 的实例
+func (gw *RDBGateway) PoolStats() map[string]sql.DBStats {
+	stats := make(map[string]sql.DBStats)
+	gw.registry().Range(func(id string, rdb common.RDB) bool {
+		if b, ok := rdb.(*breakerRDB); ok {
+			rdb = b.RDB
+		}
+		if p, ok := rdb.(StatsProvider); ok {
+			stats[id] = p.DBStats()
+		}
+		return true
+	})
+	return stats
 }
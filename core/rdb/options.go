@@ -0,0 +1,136 @@
+package rdb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+// Options 从 RDBConfig.Metadata 解析的实例选项，例如
+// {"maxOpenConns": 20, "connMaxLifetime": 300, "pragmas": {"journal_mode": "WAL", "busy_timeout": "5000"}}
+type Options struct {
+	MaxOpenConns    int `json:"maxOpenConns,omitempty"`    // 0 表示不限制
+	MaxIdleConns    int `json:"maxIdleConns,omitempty"`    // 0 使用 database/sql 默认值 2
+	ConnMaxLifetime int `json:"connMaxLifetime,omitempty"` // 秒
+	ConnMaxIdleTime int `json:"connMaxIdleTime,omitempty"` // 秒
+	// Mode 为 read-write、read-only 或 append-only，见 RDBCore.checkAccess；ReadOnly 为 true 等同于 read-only
+	Mode     common.AccessMode `json:"mode,omitempty"`
+	ReadOnly bool              `json:"readOnly,omitempty"`
+	Pragmas  map[string]string `json:"pragmas,omitempty"` // 仅 sqlite，每个连接打开时执行
+}
+
+var pragmaName = regexp.MustCompile(`^[a-z_]+$`)
+
+func (o *Options) validate(rdbType string) error {
+	if o.MaxOpenConns < 0 || o.MaxIdleConns < 0 || o.ConnMaxLifetime < 0 || o.ConnMaxIdleTime < 0 {
+		return errors.New("metadata: values must not be negative")
+	}
+	if len(o.Pragmas) > 0 && rdbType != "sqlite" {
+		return fmt.Errorf("metadata: pragmas are only supported by sqlite, not %s", rdbType)
+	}
+	for name, value := range o.Pragmas {
+		if !pragmaName.MatchString(name) {
+			return fmt.Errorf("metadata: invalid pragma name %q", name)
+		}
+		if strings.ContainsAny(value, "()&;") {
+			return fmt.Errorf("metadata: invalid value for pragma %s: %q", name, value)
+		}
+	}
+	mode, err := common.ResolveAccessMode(o.Mode, o.ReadOnly)
+	if err != nil {
+		return err
+	}
+	o.Mode, o.ReadOnly = mode, mode == common.ModeReadOnly
+	return nil
+}
+
+// applyPool 设置连接池参数
+func (o *Options) applyPool(db *sql.DB) {
+	db.SetMaxOpenConns(o.MaxOpenConns)
+	if o.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(o.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(time.Duration(o.ConnMaxLifetime) * time.Second)
+	db.SetConnMaxIdleTime(time.Duration(o.ConnMaxIdleTime) * time.Second)
+}
+
+// sqliteDSN 把 pragma 与只读转换为 modernc sqlite 的 _pragma 参数
+func (o *Options) sqliteDSN(path string) string {
+	var pragmas []string
+	for name, value := range o.Pragmas {
+		pragmas = append(pragmas, fmt.Sprintf("%s(%s)", name, value))
+	}
+	if o.Mode == common.ModeReadOnly {
+		pragmas = append(pragmas, "query_only(1)")
+	}
+	if len(pragmas) == 0 {
+		return path
+	}
+	slices.Sort(pragmas)
+	q := url.Values{"_pragma": pragmas}
+	return path + "?" + q.Encode()
+}
+
+// postgresDSN 只读时让每个连接默认使用只读事务，与网关内的语句检查互为补充
+func (o *Options) postgresDSN(dsn string) (string, error) {
+	if o.Mode != common.ModeReadOnly {
+		return dsn, nil
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	options := strings.TrimSpace(q.Get("options") + " -c default_transaction_read_only=on")
+	q.Set("options", options)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// ParseOptions 解析并校验实例选项，Metadata 为空时返回零值
+func ParseOptions(conf common.RDBConfig) (*ParsedRDBURL, *Options, error) {
+	parsed, err := ParseRDBURL(conf.URL)
+	if err != nil {
+		return nil, nil, common.InvalidURL(err)
+	}
+	opts := &Options{}
+	if err := common.DecodeMetadata(conf.Metadata, opts); err != nil {
+		return nil, nil, err
+	}
+	if err := opts.validate(parsed.Type); err != nil {
+		return nil, nil, err
+	}
+	return parsed, opts, nil
+}
+
+// Check 校验单个实例的 URL、选项以及是否支持该类型，不启动实例；返回后端类型
+func Check(conf common.RDBConfig) (string, error) {
+	parsed, _, err := ParseOptions(conf)
+	if err != nil {
+		return "", err
+	}
+	switch parsed.Type {
+	case "postgres", "sqlite":
+		return parsed.Type, nil
+	default:
+		return parsed.Type, fmt.Errorf("unsupported RDB type: %s", parsed.Type)
+	}
+}
+
+// Validate 校验所有实例的 URL 与选项，用于在启动实例之前发现配置错误
+func Validate(confs []common.RDBConfig) error {
+	var errs []error
+	for _, conf := range confs {
+		if _, err := Check(conf); err != nil {
+			errs = append(errs, fmt.Errorf("RDB %s: %w", conf.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,118 @@
+package rdb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+func TestSqliteOptions(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "a.db")
+	url := "sqlite://" + path
+
+	rw, err := newRDB(common.RDBConfig{ID: "rw", URL: url, Metadata: map[string]any{
+		"maxOpenConns": 1,
+		"pragmas":      map[string]any{"journal_mode": "WAL"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rw.Close()
+	if err := rw.Exec(ctx, "CREATE TABLE t (x INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	// WAL 模式下写入后会出现 -wal 文件
+	if _, err := os.Stat(path + "-wal"); err != nil {
+		t.Errorf("journal_mode pragma not applied: %v", err)
+	}
+	if st := rw.(StatsProvider).DBStats(); st.MaxOpenConnections != 1 {
+		t.Errorf("maxOpenConns not applied: %d", st.MaxOpenConnections)
+	}
+
+	ro, err := newRDB(common.RDBConfig{ID: "ro", URL: url, Metadata: map[string]any{"readOnly": true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	if err := ro.Exec(ctx, "INSERT INTO t (x) VALUES (1)"); err == nil {
+		t.Error("write succeeded on read-only instance")
+	}
+	if _, err := ro.Query(ctx, "SELECT x FROM t"); err != nil {
+		t.Errorf("read failed on read-only instance: %v", err)
+	}
+
+	if err := Validate([]common.RDBConfig{{ID: "p", URL: "postgres://localhost/db", Metadata: map[string]any{"pragmas": map[string]any{"foreign_keys": "on"}}}}); err == nil {
+		t.Error("expected pragmas to be rejected for postgres")
+	}
+}
+
+func TestAccessModes(t *testing.T) {
+	ctx := context.Background()
+	url := "sqlite://" + filepath.Join(t.TempDir(), "a.db")
+
+	rw, err := newRDB(common.RDBConfig{ID: "rw", URL: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rw.Close()
+	if err := rw.Exec(ctx, "CREATE TABLE t (x INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 无法解析的语句不能被跳过，否则之后的参数会错位
+	err = rw.Batch(ctx, []string{"INSERT INTO t (x) VALUES (?)", "NOT A STATEMENT", "INSERT INTO t (x) VALUES (?)"}, [][]any{{7}, nil, {8}})
+	if err == nil || !strings.Contains(err.Error(), "statement 2") {
+		t.Errorf("batch with unparsable statement: got %v", err)
+	}
+
+	ao, err := newRDB(common.RDBConfig{ID: "ao", URL: url, Metadata: map[string]any{"mode": "append-only"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ao.Close()
+	if err := ao.Exec(ctx, "INSERT INTO t (x) VALUES (?)", 1); err != nil {
+		t.Errorf("insert failed on append-only instance: %v", err)
+	}
+	for _, stmt := range []string{
+		"UPDATE t SET x = 2",
+		"DELETE FROM t",
+		"DROP TABLE t",
+		"INSERT INTO t (x) VALUES (1) ON CONFLICT (x) DO UPDATE SET x = 3",
+	} {
+		if err := ao.Exec(ctx, stmt); common.ErrorStatus(err) != 403 {
+			t.Errorf("%s on append-only instance: got %v", stmt, err)
+		}
+	}
+	if err := ao.Batch(ctx, []string{"INSERT INTO t (x) VALUES (5)", "DELETE FROM t"}, [][]any{nil, nil}); common.ErrorStatus(err) != 403 {
+		t.Errorf("batch with DELETE on append-only instance: got %v", err)
+	}
+
+	ro, err := newRDB(common.RDBConfig{ID: "ro", URL: url, Metadata: map[string]any{"mode": "read-only"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	if err := ro.Exec(ctx, "INSERT INTO t (x) VALUES (9)"); common.ErrorStatus(err) != 403 {
+		t.Errorf("insert on read-only instance: got %v", err)
+	}
+	data, err := ro.Query(ctx, "SELECT x FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "x\n1\n" {
+		t.Errorf("unexpected rows: %q", data)
+	}
+	// 只读实例上的 Exec 在只读事务中执行
+	if err := ro.Exec(ctx, "SELECT x FROM t"); err != nil {
+		t.Errorf("select via Exec on read-only instance: %v", err)
+	}
+
+	if err := Validate([]common.RDBConfig{{ID: "x", URL: url, Metadata: map[string]any{"mode": "append-only", "readOnly": true}}}); err == nil {
+		t.Error("expected append-only with readOnly to be rejected")
+	}
+}
@@ -1,6 +1,7 @@
 package rdb
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -14,21 +15,24 @@ type PsqlRDB struct {
 	db   *sql.DB
 	core *RDBCore
 	dsn  string
+	opts *Options
 }
 
-func NewPsqlRDB(dsn string) *PsqlRDB {
+func NewPsqlRDB(dsn string, opts *Options) *PsqlRDB {
+	if opts == nil {
+		opts = &Options{}
+	}
 	rdb := &PsqlRDB{
-		dsn: dsn,
+		dsn:  dsn,
+		opts: opts,
 	}
 	return rdb
 }
 
 // Execute executes a DML/DDL statement with optional parameters
-func (r *PsqlRDB) Exec(stmt string, args ...any) error {
+func (r *PsqlRDB) Exec(ctx context.Context, stmt string, args ...any) error {
 	// Convert ? placeholders to $1, $2, etc. for PostgreSQL
 	stmt, err := convertPlaceholders(stmt)
-	log := ebpg.String("Converted statement: %s\n", stmt)
-	fmt.Println(log)
 	if err != nil {
 		return err
 	}
@@ -38,7 +42,7 @@ func (r *PsqlRDB) Exec(stmt string, args ...any) error {
 		return err
 	}
 
-	err = r.core.Exec(stmt, args...)
+	err = r.core.Exec(ctx, stmt, args...)
 	if err != nil {
 		return err
 	}
@@ -46,7 +50,7 @@ func (r *PsqlRDB) Exec(stmt string, args ...any) error {
 }
 
 // Query executes a SELECT statement with optional parameters and returns CSV
-func (r *PsqlRDB) Query(stmt string, args ...any) ([]byte, error) {
+func (r *PsqlRDB) Query(ctx context.Context, stmt string, args ...any) ([]byte, error) {
 	// Convert ? placeholders to $1, $2, etc. for PostgreSQL
 	stmt, err := convertPlaceholders(stmt)
 	if err != nil {
@@ -57,7 +61,7 @@ func (r *PsqlRDB) Query(stmt string, args ...any) ([]byte, error) {
 	if err := validateParamsPsql(stmt, args); err != nil {
 		return nil, err
 	}
-	data, err := r.core.Query(stmt, args...)
+	data, err := r.core.Query(ctx, stmt, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -65,12 +69,10 @@ func (r *PsqlRDB) Query(stmt string, args ...any) ([]byte, error) {
 }
 
 // Batch executes multiple SQL statements (text format)
-func (r *PsqlRDB) Batch(stmts []string, args [][]any) error {
+func (r *PsqlRDB) Batch(ctx context.Context, stmts []string, args [][]any) error {
 	// Convert ? placeholders to $1, $2, etc. for PostgreSQL
 	for i, stmt := range stmts {
 		convertedStmt, err := convertPlaceholders(stmt)
-		log := ebpg.String("Converted statement: %s\n", convertedStmt)
-		fmt.Println(log)
 		if err != nil {
 			return err
 		}
@@ -84,7 +86,7 @@ func (r *PsqlRDB) Batch(stmts []string, args [][]any) error {
 		}
 	}
 
-	err := r.core.Batch(stmts, args)
+	err := r.core.Batch(ctx, stmts, args)
 	if err != nil {
 		return err
 	}
@@ -96,10 +98,19 @@ func (r *PsqlRDB) Start() error {
 	if err != nil {
 		return err
 	}
+	r.opts.applyPool(db)
+	// sql.Open 不会建立连接，这里确认数据库可达
+	ctx, cancel := context.WithTimeout(context.Background(), startPingTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return ebpg.Error("ping failed: %v", err)
+	}
 	r.db = db
 	r.core = &RDBCore{
 		db:      db,
 		rdbType: r.Type(),
+		mode:    r.opts.Mode,
 	}
 	return nil
 }
@@ -111,6 +122,21 @@ func (r *PsqlRDB) Close() error {
 	return nil
 }
 
+func (r *PsqlRDB) Ping(ctx context.Context) error {
+	if r.db == nil {
+		return ebpg.Error("not started")
+	}
+	return r.db.PingContext(ctx)
+}
+
+// DBStats returns the connection pool statistics, zero before Start
+func (r *PsqlRDB) DBStats() sql.DBStats {
+	if r.core == nil {
+		return sql.DBStats{}
+	}
+	return r.core.Stats()
+}
+
 func (r *PsqlRDB) Type() string {
 	return "postgres"
 }
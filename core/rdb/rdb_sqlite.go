@@ -1,9 +1,9 @@
 package rdb
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	common "jabberwocky238/combinator/core/common"
 	"strings"
 
 	_ "modernc.org/sqlite"
@@ -15,22 +15,24 @@ type SqliteRDB struct {
 	db   *sql.DB
 	core *RDBCore
 	url  string
+	opts *Options
 }
 
-func NewSqliteRDB(url string) *SqliteRDB {
-	return &SqliteRDB{url: url}
+func NewSqliteRDB(url string, opts *Options) *SqliteRDB {
+	if opts == nil {
+		opts = &Options{}
+	}
+	return &SqliteRDB{url: url, opts: opts}
 }
 
 // Execute executes a DML/DDL statement with optional parameters
-func (r *SqliteRDB) Exec(stmt string, args ...any) error {
+func (r *SqliteRDB) Exec(ctx context.Context, stmt string, args ...any) error {
 	// Validate parameters
 	var err error
 	if err = validateParams(stmt, args); err != nil {
 		return err
 	}
-	fmt.Println("[INFO] Executing statement:", stmt)
-
-	err = r.core.Exec(stmt, args...)
+	err = r.core.Exec(ctx, stmt, args...)
 	if err != nil {
 		return err
 	}
@@ -39,13 +41,13 @@ func (r *SqliteRDB) Exec(stmt string, args ...any) error {
 }
 
 // Query executes a SELECT statement with optional parameters and returns CSV
-func (r *SqliteRDB) Query(stmt string, args ...any) ([]byte, error) {
+func (r *SqliteRDB) Query(ctx context.Context, stmt string, args ...any) ([]byte, error) {
 	// Validate parameters
 	if err := validateParams(stmt, args); err != nil {
 		return nil, err
 	}
 
-	data, err := r.core.Query(stmt, args...)
+	data, err := r.core.Query(ctx, stmt, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -54,11 +56,10 @@ func (r *SqliteRDB) Query(stmt string, args ...any) ([]byte, error) {
 }
 
 // Batch executes multiple SQL statements (text format)
-func (r *SqliteRDB) Batch(stmts []string, args [][]any) error {
-	err := r.core.Batch(stmts, args)
+func (r *SqliteRDB) Batch(ctx context.Context, stmts []string, args [][]any) error {
+	err := r.core.Batch(ctx, stmts, args)
 	if err != nil {
-		common.Logger.Errorf("Batch execution error: %v", err)
-		return ebsqlite.Error("Batch execution error: %v", err)
+		return ebsqlite.Wrap(err, "Batch execution error")
 	}
 	return err
 }
@@ -68,10 +69,19 @@ func (r *SqliteRDB) Start() error {
 	if err != nil {
 		return err
 	}
+	r.opts.applyPool(sqlite_db)
+	// 打开文件失败（例如目录不存在）要到第一次连接时才会暴露
+	ctx, cancel := context.WithTimeout(context.Background(), startPingTimeout)
+	defer cancel()
+	if err := sqlite_db.PingContext(ctx); err != nil {
+		sqlite_db.Close()
+		return ebsqlite.Error("ping failed: %v", err)
+	}
 	r.db = sqlite_db
 	r.core = &RDBCore{
 		db:      sqlite_db,
 		rdbType: r.Type(),
+		mode:    r.opts.Mode,
 	}
 	return nil
 }
@@ -83,6 +93,21 @@ func (r *SqliteRDB) Close() error {
 	return nil
 }
 
+func (r *SqliteRDB) Ping(ctx context.Context) error {
+	if r.db == nil {
+		return ebsqlite.Error("not started")
+	}
+	return r.db.PingContext(ctx)
+}
+
+// DBStats returns the connection pool statistics, zero before Start
+func (r *SqliteRDB) DBStats() sql.DBStats {
+	if r.core == nil {
+		return sql.DBStats{}
+	}
+	return r.core.Stats()
+}
+
 func (r *SqliteRDB) Type() string {
 	return "sqlite"
 }
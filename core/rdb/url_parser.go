@@ -5,6 +5,8 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+
+	common "jabberwocky238/combinator/core/common"
 )
 
 // ParsedRDBURL contains parsed database connection information
@@ -25,7 +27,7 @@ type ParsedRDBURL struct {
 //   - sqlite:///path/to/db.db
 //   - sqlite://:memory:
 func ParseRDBURL(rawURL string) (*ParsedRDBURL, error) {
-	u, err := url.Parse(rawURL)
+	u, err := common.ParseURL(rawURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid URL: %w", err)
 	}
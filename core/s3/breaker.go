@@ -0,0 +1,51 @@
+package s3
+
+import (
+	"context"
+
+	"github.com/minio/minio-go/v7"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+// breakerS3 经过熔断器调用底层 S3，Start / Close / Ping 以及在本地计算的预签名 URL 不经过熔断器
+type breakerS3 struct {
+	common.S3
+	breaker *common.Breaker
+}
+
+func newBreakerS3(s3 common.S3, b *common.Breaker) *breakerS3 {
+	return &breakerS3{S3: s3, breaker: b}
+}
+
+// isS3Failure 除连接错误和超时外，服务端 5xx 也计入熔断
+func isS3Failure(err error) bool {
+	return common.IsTransientError(err) || minio.ToErrorResponse(err).StatusCode >= 500
+}
+
+func (s *breakerS3) Breaker() *common.Breaker { return s.breaker }
+func (s *breakerS3) MaxValueSize() int64      { return common.MaxValueSizeOf(s.S3) }
+
+func (s *breakerS3) Get(ctx context.Context, key string) ([]byte, error) {
+	return common.Call(s.breaker, ctx, func(ctx context.Context) ([]byte, error) {
+		return s.S3.Get(ctx, key)
+	})
+}
+
+func (s *breakerS3) Put(ctx context.Context, key string, value []byte) error {
+	return s.breaker.Do(ctx, func(ctx context.Context) error {
+		return s.S3.Put(ctx, key, value)
+	})
+}
+
+func (s *breakerS3) List(ctx context.Context, prefix string) ([]string, error) {
+	return common.Call(s.breaker, ctx, func(ctx context.Context) ([]string, error) {
+		return s.S3.List(ctx, prefix)
+	})
+}
+
+func (s *breakerS3) Delete(ctx context.Context, key string) error {
+	return s.breaker.Do(ctx, func(ctx context.Context) error {
+		return s.S3.Delete(ctx, key)
+	})
+}
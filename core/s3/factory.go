@@ -6,8 +6,9 @@ import (
 	common "jabberwocky238/combinator/core/common"
 )
 
-// S3Factory is a function that creates a S3 instance from a parsed URL
-type S3Factory func(*ParsedS3URL) (common.S3, error)
+// S3Factory is a function that creates a S3 instance from a parsed URL and its options;
+// backend-specific options (presign expiry) are applied by the factory itself
+type S3Factory func(*ParsedS3URL, *Options) (common.S3, error)
 
 var s3Factories = make(map[string]S3Factory)
 
@@ -16,11 +17,19 @@ func RegisterS3Factory(s3Type string, factory S3Factory) {
 	s3Factories[s3Type] = factory
 }
 
-// CreateS3 creates a S3 instance based on the parsed URL
-func CreateS3(parsed *ParsedS3URL) (common.S3, error) {
+// CreateS3 creates a S3 instance based on the parsed URL and options;
+// key prefix, object size limit and read-only are applied here for every backend
+func CreateS3(parsed *ParsedS3URL, opts *Options) (common.S3, error) {
 	factory, ok := s3Factories[parsed.Type]
 	if !ok {
 		return nil, fmt.Errorf("unsupported S3 type: %s", parsed.Type)
 	}
-	return factory(parsed)
+	s3, err := factory(parsed, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.KeyPrefix != "" || opts.MaxValueSize > 0 || opts.ReadOnly {
+		s3 = &optionsS3{S3: s3, opts: opts}
+	}
+	return s3, nil
 }
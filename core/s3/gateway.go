@@ -1,27 +1,34 @@
 package s3
 
 import (
+	"errors"
+	"net/http"
+	"time"
+
 	"github.com/gin-gonic/gin"
 
 	common "jabberwocky238/combinator/core/common"
 )
 
+// startTimeout 实例启动时连通性检查的超时
+const startTimeout = 10 * time.Second
+
 type S3Gateway struct {
-	grg    *gin.RouterGroup
-	S3Conf []common.S3Config
-	S3Map  map[string]common.S3
+	grg      *gin.RouterGroup
+	reg      *common.Registry[common.S3Config, common.S3]
+	initConf []common.S3Config
 }
 
 func NewGateway(grg *gin.RouterGroup, conf []common.S3Config) *S3Gateway {
 	return &S3Gateway{
-		grg:    grg,
-		S3Conf: conf,
-		S3Map:  make(map[string]common.S3),
+		grg:      grg,
+		reg:      common.NewRegistry("s3", newS3),
+		initConf: conf,
 	}
 }
 
 func (gw *S3Gateway) Start() error {
-	err := gw.Reload(gw.S3Conf)
+	err := gw.Reload(gw.initConf)
 	if err != nil {
 		return err
 	}
@@ -51,9 +58,8 @@ func (gw *S3Gateway) middlewareS3() gin.HandlerFunc {
 }
 
 func (gw *S3Gateway) handleGet(c *gin.Context) {
-	s3 := gw.S3Map[c.GetString("s3_id")]
-	if s3 == nil {
-		c.JSON(400, gin.H{"error": "invalid S3 ID"})
+	s3, err := gw.lookup(c)
+	if err != nil {
 		return
 	}
 
@@ -63,9 +69,9 @@ func (gw *S3Gateway) handleGet(c *gin.Context) {
 		return
 	}
 
-	data, err := s3.Get(key)
+	data, err := s3.Get(c.Request.Context(), key)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		c.JSON(common.ErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -73,9 +79,8 @@ func (gw *S3Gateway) handleGet(c *gin.Context) {
 }
 
 func (gw *S3Gateway) handlePut(c *gin.Context) {
-	s3 := gw.S3Map[c.GetString("s3_id")]
-	if s3 == nil {
-		c.JSON(400, gin.H{"error": "invalid S3 ID"})
+	s3, err := gw.lookup(c)
+	if err != nil {
 		return
 	}
 
@@ -85,14 +90,23 @@ func (gw *S3Gateway) handlePut(c *gin.Context) {
 		return
 	}
 
+	if limit := common.MaxValueSizeOf(s3); limit > 0 {
+		// 多读一个字节以便 Put 报告超限，更大的请求体不会整个读入内存
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+1)
+	}
 	data, err := c.GetRawData()
 	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(413, gin.H{"error": common.ErrValueTooLarge.Error()})
+			return
+		}
 		c.JSON(400, gin.H{"error": "failed to read request body"})
 		return
 	}
 
-	if err := s3.Put(key, data); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+	if err := s3.Put(c.Request.Context(), key, data); err != nil {
+		c.JSON(common.ErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -100,16 +114,15 @@ func (gw *S3Gateway) handlePut(c *gin.Context) {
 }
 
 func (gw *S3Gateway) handleList(c *gin.Context) {
-	s3 := gw.S3Map[c.GetString("s3_id")]
-	if s3 == nil {
-		c.JSON(400, gin.H{"error": "invalid S3 ID"})
+	s3, err := gw.lookup(c)
+	if err != nil {
 		return
 	}
 
 	prefix := c.Query("prefix")
-	keys, err := s3.List(prefix)
+	keys, err := s3.List(c.Request.Context(), prefix)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		c.JSON(common.ErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -117,9 +130,8 @@ func (gw *S3Gateway) handleList(c *gin.Context) {
 }
 
 func (gw *S3Gateway) handleDelete(c *gin.Context) {
-	s3 := gw.S3Map[c.GetString("s3_id")]
-	if s3 == nil {
-		c.JSON(400, gin.H{"error": "invalid S3 ID"})
+	s3, err := gw.lookup(c)
+	if err != nil {
 		return
 	}
 
@@ -129,73 +141,108 @@ func (gw *S3Gateway) handleDelete(c *gin.Context) {
 		return
 	}
 
-	if err := s3.Delete(key); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+	if err := s3.Delete(c.Request.Context(), key); err != nil {
+		c.JSON(common.ErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.String(200, "OK")
 }
 
-// Reload 重新加载 S3 配置
-func (gw *S3Gateway) Reload(newConf []common.S3Config) error {
-	newIDs := make(map[string]common.S3Config)
-	for _, conf := range newConf {
-		newIDs[conf.ID] = conf
-	}
-
-	newS3Map := make(map[string]common.S3)
-
-	// 保留未变化的 S3
-	for id, s3 := range gw.S3Map {
-		if newConf, exists := newIDs[id]; exists {
-			oldConf := gw.findConfigByID(id)
-			if oldConf != nil && oldConf.URL == newConf.URL {
-				newS3Map[id] = s3
-				common.Logger.Infof("S3 %s unchanged", id)
-				delete(newIDs, id)
-				continue
-			}
-		}
-		if err := s3.Close(); err != nil {
-			common.Logger.Warnf("Failed to close S3 %s: %v", id, err)
+// lookup 取出请求对应的实例，失败时写入 400（未配置）或 503（不可用）
+func (gw *S3Gateway) lookup(c *gin.Context) (common.S3, error) {
+	s3, err := gw.reg.Lookup(c.GetString("s3_id"))
+	if err != nil {
+		status := common.LookupStatus(err)
+		if status == 400 {
+			c.JSON(400, gin.H{"error": "invalid S3 ID"})
+		} else {
+			c.JSON(status, gin.H{"error": err.Error()})
 		}
-		common.Logger.Infof("Closed S3 %s", id)
+		return nil, err
 	}
+	return s3, nil
+}
 
-	// 加载新增或变化的 S3
-	for id, conf := range newIDs {
-		parsed, err := ParseS3URL(conf.URL)
-		if err != nil {
-			common.Logger.Errorf("Failed to parse S3 URL for %s: %v", id, err)
-			return err
-		}
+// ReloadPlan 两阶段重载中已准备好的 S3 实例
+type ReloadPlan = common.RegistryPlan[common.S3Config, common.S3]
 
-		s3, err := CreateS3(parsed)
-		if err != nil {
-			common.Logger.Errorf("Failed to create S3 %s: %v", id, err)
-			return err
-		}
+func newS3(conf common.S3Config) (common.S3, error) {
+	parsed, opts, err := ParseOptions(conf)
+	if err != nil {
+		return nil, err
+	}
 
-		if err = s3.Start(); err != nil {
-			common.Logger.Errorf("Failed to start S3 %s: %v", id, err)
-			return err
-		}
+	s3, err := CreateS3(parsed, opts)
+	if err != nil {
+		return nil, err
+	}
 
-		newS3Map[id] = s3
-		common.Logger.Infof("Loaded %s S3: %s", parsed.Type, id)
+	if err = s3.Start(); err != nil {
+		return nil, err
 	}
+	return s3, nil
+}
 
-	gw.S3Map = newS3Map
-	gw.S3Conf = newConf
-	return nil
+// Open 创建并启动一个独立于网关的实例，用于 config validate --connect，调用方负责 Close
+func Open(conf common.S3Config) (common.S3, error) {
+	return newS3(conf)
 }
 
-func (gw *S3Gateway) findConfigByID(id string) *common.S3Config {
-	for _, conf := range gw.S3Conf {
-		if conf.ID == id {
-			return &conf
-		}
-	}
-	return nil
+// Prepare 第一阶段：启动所有新增或变化的实例，不影响正在服务的实例
+func (gw *S3Gateway) Prepare(newConf []common.S3Config) (*ReloadPlan, error) {
+	return gw.reg.Prepare(newConf)
+}
+
+// Diff 计算新配置中每个实例的变化，不启动实例
+func (gw *S3Gateway) Diff(newConf []common.S3Config) []common.InstanceResult {
+	return gw.reg.Diff(newConf)
+}
+
+// Commit 第二阶段：替换实例表并关闭被替换或删除的旧实例
+func (gw *S3Gateway) Commit(plan *ReloadPlan) error {
+	return gw.reg.Commit(plan)
+}
+
+// Reload 加载配置，启动失败的实例登记为不可用并在后台重试；
+// 运行中的重载使用 Prepare / Commit
+func (gw *S3Gateway) Reload(newConf []common.S3Config) error {
+	return gw.reg.Load(newConf)
+}
+
+// SetBreaker 为之后启动的每个实例套上熔断器，参数由 policy 共享，需在 Start 之前调用
+func (gw *S3Gateway) SetBreaker(policy *common.BreakerPolicy) {
+	gw.reg.SetWrapper(func(id string, s3 common.S3) common.S3 {
+		return newBreakerS3(s3, common.NewBreaker("s3", id, policy, isS3Failure))
+	})
+}
+
+// Close 关闭所有 S3 实例，返回所有关闭失败
+func (gw *S3Gateway) Close() error {
+	return gw.reg.Close()
+}
+
+// Get 返回指定 ID 的可用实例
+func (gw *S3Gateway) Get(id string) (common.S3, bool) {
+	return gw.reg.Get(id)
+}
+
+// Has 配置中是否有该 ID 的实例，启动失败、正在后台重试的实例也算
+func (gw *S3Gateway) Has(id string) bool {
+	return gw.reg.Has(id)
+}
+
+// Count 当前可用的实例数
+func (gw *S3Gateway) Count() int {
+	return gw.reg.Count()
+}
+
+// Unavailable 返回启动失败、正在后台重试的实例
+func (gw *S3Gateway) Unavailable() []*common.UnavailableError {
+	return gw.reg.Unavailable()
+}
+
+// Range 按配置顺序遍历当前所有可用的 S3 实例
+func (gw *S3Gateway) Range(fn func(id string, s3 common.S3) bool) {
+	gw.reg.Range(fn)
 }
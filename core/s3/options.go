@@ -0,0 +1,187 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+// defaultPresignExpiry 未配置 presignExpiry 时预签名 URL 的有效期
+const defaultPresignExpiry = time.Hour
+
+// Options 从 S3Config.Metadata 解析的实例选项，例如
+// {"keyPrefix": "tenant-a/", "maxValueSize": 10485760, "presignExpiry": 600}
+type Options struct {
+	KeyPrefix    string `json:"keyPrefix,omitempty"`    // 所有 key 自动加上前缀，List 返回的 key 不含前缀
+	MaxValueSize int64  `json:"maxValueSize,omitempty"` // 单个对象的最大字节数，0 表示不限制
+	// Mode 为 read-write 或 read-only，只读时拒绝 Put / Delete 与上传预签名；
+	// S3 不支持 append-only，ReadOnly 为 true 等同于 read-only
+	Mode          common.AccessMode `json:"mode,omitempty"`
+	ReadOnly      bool              `json:"readOnly,omitempty"`
+	PresignExpiry int               `json:"presignExpiry,omitempty"` // 预签名 URL 有效期（秒），仅 minio，默认 3600
+}
+
+// Expiry 返回预签名 URL 的有效期
+func (o *Options) Expiry() time.Duration {
+	if o.PresignExpiry > 0 {
+		return time.Duration(o.PresignExpiry) * time.Second
+	}
+	return defaultPresignExpiry
+}
+
+func (o *Options) validate(s3Type string) error {
+	if o.MaxValueSize < 0 || o.PresignExpiry < 0 {
+		return errors.New("metadata: values must not be negative")
+	}
+	// S3 预签名 URL 最长 7 天
+	if o.PresignExpiry > 7*24*3600 {
+		return errors.New("metadata: presignExpiry must not exceed 7 days")
+	}
+	if s3Type != "minio" && o.PresignExpiry > 0 {
+		return fmt.Errorf("metadata: presignExpiry is only supported by minio, not %s", s3Type)
+	}
+	mode, err := common.ResolveAccessMode(o.Mode, o.ReadOnly)
+	if err != nil {
+		return err
+	}
+	if mode == common.ModeAppendOnly {
+		return errors.New("metadata: append-only mode is only supported by RDB")
+	}
+	o.Mode, o.ReadOnly = mode, mode == common.ModeReadOnly
+	return nil
+}
+
+// ParseOptions 解析并校验实例选项，Metadata 为空时返回零值
+func ParseOptions(conf common.S3Config) (*ParsedS3URL, *Options, error) {
+	parsed, err := ParseS3URL(conf.URL)
+	if err != nil {
+		return nil, nil, common.InvalidURL(err)
+	}
+	opts := &Options{}
+	if err := common.DecodeMetadata(conf.Metadata, opts); err != nil {
+		return nil, nil, err
+	}
+	if err := opts.validate(parsed.Type); err != nil {
+		return nil, nil, err
+	}
+	return parsed, opts, nil
+}
+
+// Check 校验单个实例的 URL、选项以及该类型是否已注册，不启动实例；返回后端类型
+func Check(conf common.S3Config) (string, error) {
+	parsed, _, err := ParseOptions(conf)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := s3Factories[parsed.Type]; !ok {
+		return parsed.Type, fmt.Errorf("unsupported S3 type: %s", parsed.Type)
+	}
+	return parsed.Type, nil
+}
+
+// Validate 校验所有实例的 URL 与选项，用于在启动实例之前发现配置错误
+func Validate(confs []common.S3Config) error {
+	var errs []error
+	for _, conf := range confs {
+		if _, err := Check(conf); err != nil {
+			errs = append(errs, fmt.Errorf("S3 %s: %w", conf.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// optionsS3 实现与后端无关的选项：key 前缀、对象大小限制与只读
+type optionsS3 struct {
+	common.S3
+	opts *Options
+}
+
+func (s *optionsS3) MaxValueSize() int64 { return s.opts.MaxValueSize }
+
+// key 加上 keyPrefix；按路径清理后离开前缀的 key（例如 "../other-tenant/x"）返回 ErrInvalidKey
+func (s *optionsS3) key(key string) (string, error) {
+	prefix := s.opts.KeyPrefix
+	if prefix == "" || key == "" {
+		return prefix + key, nil
+	}
+	want := path.Clean("/" + prefix)
+	if strings.HasSuffix(prefix, "/") && want != "/" {
+		want += "/"
+	}
+	if !strings.HasPrefix(path.Clean("/"+prefix+key), want) {
+		return "", fmt.Errorf("%w: %q", common.ErrInvalidKey, key)
+	}
+	return prefix + key, nil
+}
+
+func (s *optionsS3) Get(ctx context.Context, key string) ([]byte, error) {
+	k, err := s.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.S3.Get(ctx, k)
+}
+
+func (s *optionsS3) Put(ctx context.Context, key string, value []byte) error {
+	if s.opts.ReadOnly {
+		return common.ErrReadOnly
+	}
+	if s.opts.MaxValueSize > 0 && int64(len(value)) > s.opts.MaxValueSize {
+		return fmt.Errorf("%w: %d > %d bytes", common.ErrValueTooLarge, len(value), s.opts.MaxValueSize)
+	}
+	k, err := s.key(key)
+	if err != nil {
+		return err
+	}
+	return s.S3.Put(ctx, k, value)
+}
+
+func (s *optionsS3) List(ctx context.Context, prefix string) ([]string, error) {
+	k, err := s.key(prefix)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := s.S3.List(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+	for i, k := range keys {
+		keys[i] = strings.TrimPrefix(k, s.opts.KeyPrefix)
+	}
+	return keys, nil
+}
+
+func (s *optionsS3) Delete(ctx context.Context, key string) error {
+	if s.opts.ReadOnly {
+		return common.ErrReadOnly
+	}
+	k, err := s.key(key)
+	if err != nil {
+		return err
+	}
+	return s.S3.Delete(ctx, k)
+}
+
+func (s *optionsS3) GeneratePresignedUploadURL(ctx context.Context, key string) (string, error) {
+	if s.opts.ReadOnly {
+		return "", common.ErrReadOnly
+	}
+	k, err := s.key(key)
+	if err != nil {
+		return "", err
+	}
+	return s.S3.GeneratePresignedUploadURL(ctx, k)
+}
+
+func (s *optionsS3) GeneratePresignedDownloadURL(ctx context.Context, key string) (string, error) {
+	k, err := s.key(key)
+	if err != nil {
+		return "", err
+	}
+	return s.S3.GeneratePresignedDownloadURL(ctx, k)
+}
@@ -0,0 +1,53 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+func TestKeyTraversal(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	tenant, err := newS3(common.S3Config{ID: "a", URL: "local://" + filepath.Join(root, "data"), Metadata: map[string]any{
+		"keyPrefix": "tenant-a/",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tenant.Put(ctx, "dir/../x", []byte("1")); err != nil {
+		t.Fatalf("key inside the prefix rejected: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "data", "tenant-a", "x")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 离开 keyPrefix 或存储目录的 key 返回 400，不访问任何文件
+	for _, key := range []string{"../tenant-b/x", "../../escape", "x/../../tenant-ab/x", "/../../../etc/passwd"} {
+		if err := tenant.Put(ctx, key, []byte("1")); !errors.Is(err, common.ErrInvalidKey) || common.ErrorStatus(err) != 400 {
+			t.Errorf("put %q: want ErrInvalidKey, got %v", key, err)
+		}
+		if _, err := tenant.Get(ctx, key); !errors.Is(err, common.ErrInvalidKey) {
+			t.Errorf("get %q: want ErrInvalidKey, got %v", key, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "escape")); !os.IsNotExist(err) {
+		t.Error("file written outside the storage directory")
+	}
+
+	// 没有 keyPrefix 时同样不能离开存储目录
+	plain, err := newS3(common.S3Config{ID: "b", URL: "local://" + filepath.Join(root, "data")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.Put(ctx, "../escape", []byte("1")); !errors.Is(err, common.ErrInvalidKey) {
+		t.Errorf("want ErrInvalidKey, got %v", err)
+	}
+	if err := plain.Delete(ctx, "../../x"); !errors.Is(err, common.ErrInvalidKey) {
+		t.Errorf("want ErrInvalidKey, got %v", err)
+	}
+}
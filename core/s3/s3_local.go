@@ -1,6 +1,7 @@
 package s3
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,7 +11,7 @@ import (
 )
 
 func init() {
-	RegisterS3Factory("local", func(parsed *ParsedS3URL) (common.S3, error) {
+	RegisterS3Factory("local", func(parsed *ParsedS3URL, opts *Options) (common.S3, error) {
 		return NewLocalS3(parsed.Path), nil
 	})
 }
@@ -33,16 +34,36 @@ func (s *LocalS3) Close() error {
 	return nil
 }
 
+// Ping 检查存储目录可写
+func (s *LocalS3) Ping(ctx context.Context) error {
+	f, err := os.CreateTemp(s.basePath, ".combinator-ping-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
+
 func (s *LocalS3) Type() string {
 	return "local"
 }
 
-func (s *LocalS3) getFullPath(key string) string {
-	return filepath.Join(s.basePath, key)
+// getFullPath 返回 key 对应的文件路径，清理后不在 basePath 之下的 key 返回 ErrInvalidKey
+func (s *LocalS3) getFullPath(key string) (string, error) {
+	base := filepath.Clean(s.basePath)
+	fullPath := filepath.Join(base, filepath.FromSlash(key))
+	if fullPath != base && !strings.HasPrefix(fullPath, base+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q", common.ErrInvalidKey, key)
+	}
+	return fullPath, nil
 }
 
-func (s *LocalS3) Get(key string) ([]byte, error) {
-	fullPath := s.getFullPath(key)
+func (s *LocalS3) Get(ctx context.Context, key string) ([]byte, error) {
+	fullPath, err := s.getFullPath(key)
+	if err != nil {
+		return nil, err
+	}
 	data, err := os.ReadFile(fullPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
@@ -50,8 +71,11 @@ func (s *LocalS3) Get(key string) ([]byte, error) {
 	return data, nil
 }
 
-func (s *LocalS3) Put(key string, value []byte) error {
-	fullPath := s.getFullPath(key)
+func (s *LocalS3) Put(ctx context.Context, key string, value []byte) error {
+	fullPath, err := s.getFullPath(key)
+	if err != nil {
+		return err
+	}
 	dir := filepath.Dir(fullPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
@@ -62,14 +86,20 @@ func (s *LocalS3) Put(key string, value []byte) error {
 	return nil
 }
 
-func (s *LocalS3) List(prefix string) ([]string, error) {
+func (s *LocalS3) List(ctx context.Context, prefix string) ([]string, error) {
 	var keys []string
-	prefixPath := s.getFullPath(prefix)
+	prefixPath, err := s.getFullPath(prefix)
+	if err != nil {
+		return nil, err
+	}
 
-	err := filepath.Walk(s.basePath, func(path string, info os.FileInfo, err error) error {
+	err = filepath.Walk(s.basePath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if info.IsDir() {
 			return nil
 		}
@@ -78,7 +108,7 @@ func (s *LocalS3) List(prefix string) ([]string, error) {
 			return err
 		}
 		relPath = filepath.ToSlash(relPath)
-		if prefix == "" || strings.HasPrefix(s.getFullPath(relPath), prefixPath) {
+		if prefix == "" || strings.HasPrefix(path, prefixPath) {
 			keys = append(keys, relPath)
 		}
 		return nil
@@ -90,18 +120,21 @@ func (s *LocalS3) List(prefix string) ([]string, error) {
 	return keys, nil
 }
 
-func (s *LocalS3) Delete(key string) error {
-	fullPath := s.getFullPath(key)
+func (s *LocalS3) Delete(ctx context.Context, key string) error {
+	fullPath, err := s.getFullPath(key)
+	if err != nil {
+		return err
+	}
 	if err := os.Remove(fullPath); err != nil {
 		return fmt.Errorf("failed to delete file: %w", err)
 	}
 	return nil
 }
 
-func (s *LocalS3) GeneratePresignedUploadURL(key string) (string, error) {
+func (s *LocalS3) GeneratePresignedUploadURL(ctx context.Context, key string) (string, error) {
 	return "", fmt.Errorf("presigned URLs not supported for local storage")
 }
 
-func (s *LocalS3) GeneratePresignedDownloadURL(key string) (string, error) {
+func (s *LocalS3) GeneratePresignedDownloadURL(ctx context.Context, key string) (string, error) {
 	return "", fmt.Errorf("presigned URLs not supported for local storage")
 }
@@ -11,11 +11,12 @@ import (
 	"github.com/minio/minio-go/v7/pkg/credentials"
 
 	common "jabberwocky238/combinator/core/common"
+	trace "jabberwocky238/combinator/core/trace"
 )
 
 func init() {
-	RegisterS3Factory("minio", func(parsed *ParsedS3URL) (common.S3, error) {
-		return NewMinioS3(parsed)
+	RegisterS3Factory("minio", func(parsed *ParsedS3URL, opts *Options) (common.S3, error) {
+		return NewMinioS3(parsed, opts.Expiry())
 	})
 }
 
@@ -23,10 +24,10 @@ type MinioS3 struct {
 	client *minio.Client
 	bucket string
 	config *ParsedS3URL
-	ctx    context.Context
+	expiry time.Duration // 预签名 URL 有效期
 }
 
-func NewMinioS3(parsed *ParsedS3URL) (*MinioS3, error) {
+func NewMinioS3(parsed *ParsedS3URL, expiry time.Duration) (*MinioS3, error) {
 	endpoint := parsed.Host
 	if parsed.Port != "" {
 		endpoint = fmt.Sprintf("%s:%s", parsed.Host, parsed.Port)
@@ -44,23 +45,38 @@ func NewMinioS3(parsed *ParsedS3URL) (*MinioS3, error) {
 		client: client,
 		bucket: parsed.Bucket,
 		config: parsed,
-		ctx:    context.Background(),
+		expiry: expiry,
 	}, nil
 }
 
 func (s *MinioS3) Start() error {
-	exists, err := s.client.BucketExists(s.ctx, s.bucket)
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	defer cancel()
+
+	exists, err := s.client.BucketExists(ctx, s.bucket)
 	if err != nil {
 		return fmt.Errorf("failed to check bucket: %w", err)
 	}
 	if !exists {
-		if err := s.client.MakeBucket(s.ctx, s.bucket, minio.MakeBucketOptions{}); err != nil {
+		if err := s.client.MakeBucket(ctx, s.bucket, minio.MakeBucketOptions{}); err != nil {
 			return fmt.Errorf("failed to create bucket: %w", err)
 		}
 	}
 	return nil
 }
 
+// Ping 检查 bucket 存在
+func (s *MinioS3) Ping(ctx context.Context) error {
+	exists, err := s.client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("bucket %s does not exist", s.bucket)
+	}
+	return nil
+}
+
 func (s *MinioS3) Close() error {
 	return nil
 }
@@ -69,38 +85,45 @@ func (s *MinioS3) Type() string {
 	return "minio"
 }
 
-func (s *MinioS3) Get(key string) ([]byte, error) {
-	obj, err := s.client.GetObject(s.ctx, s.bucket, key, minio.GetObjectOptions{})
+func (s *MinioS3) Get(ctx context.Context, key string) (data []byte, err error) {
+	ctx, span := s.startSpan(ctx, "minio.get")
+	defer func() { span.RecordError(err); span.Finish() }()
+
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get object: %w", err)
 	}
 	defer obj.Close()
 
-	data, err := io.ReadAll(obj)
+	data, err = io.ReadAll(obj)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read object: %w", err)
 	}
 	return data, nil
 }
 
-func (s *MinioS3) Put(key string, value []byte) error {
+func (s *MinioS3) Put(ctx context.Context, key string, value []byte) (err error) {
+	ctx, span := s.startSpan(ctx, "minio.put")
+	defer func() { span.RecordError(err); span.Finish() }()
+
 	reader := bytes.NewReader(value)
-	_, err := s.client.PutObject(s.ctx, s.bucket, key, reader, int64(len(value)), minio.PutObjectOptions{})
+	_, err = s.client.PutObject(ctx, s.bucket, key, reader, int64(len(value)), minio.PutObjectOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to put object: %w", err)
 	}
 	return nil
 }
 
-func (s *MinioS3) List(prefix string) ([]string, error) {
-	var keys []string
+func (s *MinioS3) List(ctx context.Context, prefix string) (keys []string, err error) {
+	ctx, span := s.startSpan(ctx, "minio.list")
+	defer func() { span.RecordError(err); span.Finish() }()
 
 	opts := minio.ListObjectsOptions{
 		Prefix:    prefix,
 		Recursive: true,
 	}
 
-	for obj := range s.client.ListObjects(s.ctx, s.bucket, opts) {
+	for obj := range s.client.ListObjects(ctx, s.bucket, opts) {
 		if obj.Err != nil {
 			return nil, fmt.Errorf("failed to list: %w", obj.Err)
 		}
@@ -109,26 +132,36 @@ func (s *MinioS3) List(prefix string) ([]string, error) {
 	return keys, nil
 }
 
-func (s *MinioS3) Delete(key string) error {
-	err := s.client.RemoveObject(s.ctx, s.bucket, key, minio.RemoveObjectOptions{})
+func (s *MinioS3) Delete(ctx context.Context, key string) (err error) {
+	ctx, span := s.startSpan(ctx, "minio.delete")
+	defer func() { span.RecordError(err); span.Finish() }()
+
+	err = s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to delete: %w", err)
 	}
 	return nil
 }
 
-func (s *MinioS3) GeneratePresignedUploadURL(key string) (string, error) {
-	url, err := s.client.PresignedPutObject(s.ctx, s.bucket, key, time.Hour)
+func (s *MinioS3) GeneratePresignedUploadURL(ctx context.Context, key string) (string, error) {
+	url, err := s.client.PresignedPutObject(ctx, s.bucket, key, s.expiry)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate presigned upload URL: %w", err)
 	}
 	return url.String(), nil
 }
 
-func (s *MinioS3) GeneratePresignedDownloadURL(key string) (string, error) {
-	url, err := s.client.PresignedGetObject(s.ctx, s.bucket, key, time.Hour, nil)
+func (s *MinioS3) GeneratePresignedDownloadURL(ctx context.Context, key string) (string, error) {
+	url, err := s.client.PresignedGetObject(ctx, s.bucket, key, s.expiry, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate presigned download URL: %w", err)
 	}
 	return url.String(), nil
 }
+
+func (s *MinioS3) startSpan(ctx context.Context, name string) (context.Context, *trace.Span) {
+	ctx, span := trace.Start(ctx, name, trace.SpanKindClient)
+	span.SetAttr("server.address", s.client.EndpointURL().Host)
+	span.SetAttr("aws.s3.bucket", s.bucket)
+	return ctx, span
+}
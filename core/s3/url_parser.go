@@ -3,6 +3,8 @@ package s3
 import (
 	"fmt"
 	"net/url"
+
+	common "jabberwocky238/combinator/core/common"
 )
 
 // ParsedS3URL contains parsed S3 store connection information
@@ -24,7 +26,7 @@ type ParsedS3URL struct {
 //   - s3://bucket@region
 //   - minio://bucket@host:port
 func ParseS3URL(rawURL string) (*ParsedS3URL, error) {
-	u, err := url.Parse(rawURL)
+	u, err := common.ParseURL(rawURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid URL: %w", err)
 	}
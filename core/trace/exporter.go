@@ -0,0 +1,118 @@
+package trace
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	common "jabberwocky238/combinator/core/common"
+)
+
+// Exporter 把结束的 span 批量发送到后端
+type Exporter interface {
+	Export(ctx context.Context, spans []*Span) error
+	Shutdown(ctx context.Context) error
+}
+
+const (
+	batchSize     = 256
+	queueSize     = 4096
+	flushInterval = 2 * time.Second
+)
+
+// tracer 在后台批量导出 span，队列满时丢弃而不是阻塞请求
+type tracer struct {
+	exporter Exporter
+	queue    chan *Span
+	done     chan struct{}
+	dropped  atomic.Int64
+	stopOnce sync.Once
+}
+
+var global atomic.Pointer[tracer]
+
+func current() *tracer {
+	return global.Load()
+}
+
+// Init 安装全局 exporter，exporter 为 nil 时关闭追踪
+func Init(exp Exporter) {
+	if exp == nil {
+		global.Store(nil)
+		return
+	}
+	t := &tracer{
+		exporter: exp,
+		queue:    make(chan *Span, queueSize),
+		done:     make(chan struct{}),
+	}
+	go t.loop()
+	global.Store(t)
+}
+
+// Shutdown 导出剩余的 span 并关闭 exporter
+func Shutdown(ctx context.Context) error {
+	t := global.Swap(nil)
+	if t == nil {
+		return nil
+	}
+	t.stopOnce.Do(func() { close(t.queue) })
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+func (t *tracer) enqueue(s *Span) {
+	defer func() {
+		// Shutdown 之后结束的 span 直接丢弃
+		if recover() != nil {
+			t.dropped.Add(1)
+		}
+	}()
+	select {
+	case t.queue <- s:
+	default:
+		if t.dropped.Add(1)%1000 == 1 {
+			common.Logger.Warnf("Trace queue full, dropped %d spans", t.dropped.Load())
+		}
+	}
+}
+
+func (t *tracer) loop() {
+	defer close(t.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := t.exporter.Export(ctx, batch); err != nil {
+			common.Logger.Warnf("Failed to export %d spans: %v", len(batch), err)
+		}
+		cancel()
+		batch = make([]*Span, 0, batchSize)
+	}
+
+	for {
+		select {
+		case s, ok := <-t.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, s)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
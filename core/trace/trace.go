@@ -0,0 +1,187 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TraceID [16]byte
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+func (s SpanID) IsValid() bool  { return s != SpanID{} }
+
+// SpanContext 是跨进程传播的部分，对应 W3C traceparent
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// SpanKind 与 OTLP 的取值保持一致
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// Span 一次操作的计时记录，End 之后交给 exporter
+type Span struct {
+	Name     string
+	Kind     SpanKind
+	Context  SpanContext
+	ParentID SpanID
+	Start    time.Time
+	End      time.Time
+	Attrs    map[string]any
+	Err      string
+
+	mu      sync.Mutex
+	ended   bool
+	tracer  *tracer
+	enabled bool
+}
+
+// SetAttr 设置 span 属性，未启用导出时为空操作
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil || !s.enabled {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Attrs == nil {
+		s.Attrs = make(map[string]any)
+	}
+	s.Attrs[key] = value
+}
+
+// RecordError 标记 span 失败，err 为 nil 时忽略
+func (s *Span) RecordError(err error) {
+	if s == nil || !s.enabled || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Err = err.Error()
+}
+
+// Finish 结束 span 并提交导出，多次调用只生效一次
+func (s *Span) Finish() {
+	if s == nil || !s.enabled {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	s.mu.Unlock()
+	s.tracer.enqueue(s)
+}
+
+type spanKey struct{}
+
+// SpanFromContext returns the current span, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// ContextWithRemote 把上游传入的 SpanContext 放入 ctx，作为下一个 span 的父节点
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanKey{}, &Span{Context: sc})
+}
+
+// Start 创建一个子 span；ctx 中没有 span 时创建新的 trace
+// 未配置 exporter 时仍生成 ID 以便传播，但不记录任何数据
+func Start(ctx context.Context, name string, kind ...SpanKind) (context.Context, *Span) {
+	t := current()
+	s := &Span{
+		Name:    name,
+		Kind:    SpanKindInternal,
+		tracer:  t,
+		enabled: t != nil,
+	}
+	if len(kind) > 0 {
+		s.Kind = kind[0]
+	}
+
+	if parent := SpanFromContext(ctx); parent != nil && parent.Context.TraceID.IsValid() {
+		s.Context.TraceID = parent.Context.TraceID
+		s.Context.Sampled = parent.Context.Sampled || s.enabled
+		s.ParentID = parent.Context.SpanID
+	} else {
+		s.Context.TraceID = newTraceID()
+		s.Context.Sampled = s.enabled
+	}
+	s.Context.SpanID = newSpanID()
+	if s.enabled {
+		s.Start = time.Now()
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// ParseTraceparent parses a W3C traceparent header: 00-<trace-id>-<parent-id>-<flags>
+func ParseTraceparent(header string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 {
+		return sc, fmt.Errorf("invalid traceparent: %q", header)
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	// 版本 ff 非法；未知的更高版本按规范尽量解析前四段
+	if len(version) != 2 || version == "ff" || (version == "00" && len(parts) != 4) {
+		return sc, fmt.Errorf("invalid traceparent version: %q", header)
+	}
+	if len(traceID) != 32 || len(spanID) != 16 || len(flags) != 2 {
+		return sc, fmt.Errorf("invalid traceparent: %q", header)
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(traceID)); err != nil {
+		return sc, fmt.Errorf("invalid trace id: %w", err)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(spanID)); err != nil {
+		return sc, fmt.Errorf("invalid parent id: %w", err)
+	}
+	var f [1]byte
+	if _, err := hex.Decode(f[:], []byte(flags)); err != nil {
+		return sc, fmt.Errorf("invalid trace flags: %w", err)
+	}
+	if !sc.TraceID.IsValid() || !sc.SpanID.IsValid() {
+		return sc, fmt.Errorf("invalid traceparent: all-zero id")
+	}
+	sc.Sampled = f[0]&0x01 == 1
+	return sc, nil
+}
+
+// Traceparent formats a SpanContext as a W3C traceparent header
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	rand.Read(id[:])
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	rand.Read(id[:])
+	return id
+}
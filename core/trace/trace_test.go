@@ -0,0 +1,40 @@
+package trace
+
+import (
+	"context"
+	"testing"
+)
+
+// TestTraceparent 测试 W3C traceparent 的解析与生成
+func TestTraceparent(t *testing.T) {
+	const header = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	sc, err := ParseTraceparent(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sc.Sampled || sc.TraceID.String() != "0af7651916cd43dd8448eb211c80319c" {
+		t.Errorf("unexpected span context: %+v", sc)
+	}
+	if got := sc.Traceparent(); got != header {
+		t.Errorf("Traceparent() = %s, want %s", got, header)
+	}
+
+	for _, bad := range []string{
+		"",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331",
+		"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"00-00000000000000000000000000000000-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-zzad6b7169203331-01",
+	} {
+		if _, err := ParseTraceparent(bad); err == nil {
+			t.Errorf("ParseTraceparent(%q) should fail", bad)
+		}
+	}
+
+	// 子 span 继承 trace id，父节点为上游 span
+	ctx := ContextWithRemote(context.Background(), sc)
+	_, child := Start(ctx, "child")
+	if child.Context.TraceID != sc.TraceID || child.ParentID != sc.SpanID {
+		t.Errorf("child span not linked to remote parent: %+v", child.Context)
+	}
+}
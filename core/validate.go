@@ -0,0 +1,266 @@
+package combinator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	auth "jabberwocky238/combinator/core/auth"
+	common "jabberwocky238/combinator/core/common"
+	kvModule "jabberwocky238/combinator/core/kv"
+	ratelimit "jabberwocky238/combinator/core/ratelimit"
+	rdbModule "jabberwocky238/combinator/core/rdb"
+	s3Module "jabberwocky238/combinator/core/s3"
+)
+
+// 实例检查结果
+const (
+	CheckOK          = "ok"
+	CheckInvalid     = "invalid"
+	CheckUnreachable = "unreachable"
+)
+
+// ValidationReport 静态检查（以及可选的连通性检查）的结果，用于 combinator config validate
+type ValidationReport struct {
+	Valid     bool            `json:"valid"`
+	Errors    []string        `json:"errors"` // 与单个实例无关的错误，例如 ${...}、auth、rateLimit
+	Instances []InstanceCheck `json:"instances"`
+}
+
+// InstanceCheck 单个实例的检查结果，URL 中的密码已隐藏
+type InstanceCheck struct {
+	Kind      string  `json:"kind"`
+	ID        string  `json:"id"`
+	Type      string  `json:"type,omitempty"`
+	URL       string  `json:"url"`
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latencyMs,omitempty"` // 仅 --connect，启动并 Ping 的耗时
+}
+
+// ValidateOptions Connect 为 true 时启动每个实例并 Ping，Timeout 为单个实例的上限
+type ValidateOptions struct {
+	Connect bool
+	Timeout time.Duration
+}
+
+// ValidateConfig 在不启动网关的情况下检查配置：${...} 引用、实例 ID 重复、URL 与 Metadata、
+// 后端类型是否已编译进来，以及 auth、rateLimit、circuitBreaker、timeouts、cors 各段
+func ValidateConfig(ctx context.Context, raw *common.Config, opts ValidateOptions) *ValidationReport {
+	report := &ValidationReport{Errors: []string{}, Instances: []InstanceCheck{}}
+
+	conf, err := common.ExpandConfig(raw)
+	if err != nil {
+		// 无法展开时仍然检查原始配置，尽量一次报告所有问题
+		report.Errors = append(report.Errors, err.Error())
+		conf = raw
+	}
+
+	// 各段的构造函数返回的错误已经带有段名
+	sections := []func() error{
+		func() error { _, err := auth.New(conf.Auth); return err },
+		func() error {
+			if err := checkRateLimitStore(conf); err != nil {
+				return err
+			}
+			_, err := ratelimit.New(conf.RateLimit, ratelimit.NewMemoryStore())
+			return err
+		},
+		func() error { _, err := common.NewBreakerSettings(conf.CircuitBreaker); return err },
+		func() error { _, err := newTimeoutPolicy(conf.Timeouts); return err },
+		func() error { _, err := newCORSPolicy(conf.CORS, nil); return err },
+	}
+	for _, check := range sections {
+		if err := check(); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+	}
+
+	for i, c := range conf.Rdb {
+		report.Instances = append(report.Instances, checkInstance("rdb", c.ID, displayURL(raw.Rdb[i].URL, c.URL), func() (string, error) { return rdbModule.Check(c) }))
+	}
+	for i, c := range conf.Kv {
+		report.Instances = append(report.Instances, checkInstance("kv", c.ID, displayURL(raw.Kv[i].URL, c.URL), func() (string, error) { return kvModule.Check(c) }))
+	}
+	for i, c := range conf.S3 {
+		report.Instances = append(report.Instances, checkInstance("s3", c.ID, displayURL(raw.S3[i].URL, c.URL), func() (string, error) { return s3Module.Check(c) }))
+	}
+	markDuplicates(report.Instances)
+
+	if opts.Connect && len(report.Errors) == 0 {
+		connectInstances(ctx, conf, report.Instances, opts.Timeout)
+	}
+
+	report.Valid = len(report.Errors) == 0
+	for _, inst := range report.Instances {
+		if inst.Status != CheckOK {
+			report.Valid = false
+		}
+	}
+	return report
+}
+
+// Err 把报告中的所有错误合并为一个 error，配置有效时返回 nil
+func (r *ValidationReport) Err() error {
+	if r.Valid {
+		return nil
+	}
+	errs := make([]error, 0, len(r.Errors))
+	for _, e := range r.Errors {
+		errs = append(errs, errors.New(e))
+	}
+	for _, inst := range r.Instances {
+		if inst.Status != CheckOK {
+			errs = append(errs, fmt.Errorf("%s %s: %s", strings.ToUpper(inst.Kind), inst.ID, inst.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// displayURL 报告中显示原始 URL（保留 ${...}），只写 ID 的实例显示默认 URL
+func displayURL(raw, resolved string) string {
+	if raw == "" {
+		return resolved
+	}
+	return raw
+}
+
+func checkInstance(kind, id, rawURL string, check func() (string, error)) InstanceCheck {
+	res := InstanceCheck{Kind: kind, ID: id, URL: common.RedactURL(rawURL), Status: CheckOK}
+	typ, err := check()
+	res.Type = typ
+	// 报告可能通过 /reload 返回，不能包含 ${...} 展开后的值
+	err = common.HideResolved(err, rawURL)
+	if id == "" {
+		err = errors.Join(errors.New("missing id"), err)
+	}
+	if err != nil {
+		res.Status = CheckInvalid
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// markDuplicates 同一类型下重复的 ID 都标记为 invalid
+func markDuplicates(checks []InstanceCheck) {
+	count := make(map[string]int)
+	for _, c := range checks {
+		count[c.Kind+"/"+c.ID]++
+	}
+	for i, c := range checks {
+		if c.ID == "" || count[c.Kind+"/"+c.ID] < 2 {
+			continue
+		}
+		checks[i].Status = CheckInvalid
+		checks[i].Error = joinError(checks[i].Error, "duplicate id")
+	}
+}
+
+// duplicateIDs 启动与重载前拒绝重复的实例 ID
+func duplicateIDs(conf *common.Config) error {
+	var errs []error
+	check := func(kind string, ids []string) {
+		seen := make(map[string]bool)
+		for _, id := range ids {
+			if seen[id] {
+				errs = append(errs, fmt.Errorf("%s: duplicate id %q", kind, id))
+			}
+			seen[id] = true
+		}
+	}
+	check("rdb", instanceIDs(conf.Rdb))
+	check("kv", instanceIDs(conf.Kv))
+	check("s3", instanceIDs(conf.S3))
+	return errors.Join(errs...)
+}
+
+func instanceIDs[C interface{ InstanceID() string }](confs []C) []string {
+	ids := make([]string, len(confs))
+	for i, c := range confs {
+		ids[i] = c.InstanceID()
+	}
+	return ids
+}
+
+// connectInstances 并发启动并 Ping 所有静态检查通过的实例，完成后立即关闭
+func connectInstances(ctx context.Context, conf *common.Config, checks []InstanceCheck, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	open := func(kind string, i int) (common.Service, error) {
+		switch kind {
+		case "rdb":
+			return rdbModule.Open(conf.Rdb[i])
+		case "kv":
+			return kvModule.Open(conf.Kv[i])
+		default:
+			return s3Module.Open(conf.S3[i])
+		}
+	}
+
+	var wg sync.WaitGroup
+	index := map[string]int{}
+	for i := range checks {
+		c := &checks[i]
+		n := index[c.Kind]
+		index[c.Kind]++
+		if c.Status != CheckOK {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := probeInstance(ctx, timeout, func() (common.Service, error) { return open(c.Kind, n) })
+			c.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+			if err != nil {
+				c.Status = CheckUnreachable
+				// c.URL 已隐藏密码，但保留了 ${...} 引用
+				c.Error = common.HideResolved(err, c.URL).Error()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func probeInstance(ctx context.Context, timeout time.Duration, open func() (common.Service, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// 各实例的 Start 有自己的超时，这里只等待到 timeout
+	type result struct {
+		svc common.Service
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		svc, err := open()
+		done <- result{svc, err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return r.err
+		}
+		defer r.svc.Close()
+		return r.svc.Ping(ctx)
+	case <-ctx.Done():
+		// 迟到的实例启动后关闭
+		go func() {
+			if r := <-done; r.err == nil {
+				r.svc.Close()
+			}
+		}()
+		return ctx.Err()
+	}
+}
+
+func joinError(prev, msg string) string {
+	if prev == "" {
+		return msg
+	}
+	return prev + "; " + msg
+}
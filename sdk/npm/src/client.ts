@@ -7,9 +7,11 @@ import type {
 
 export class Combinator {
   private baseURL: string
+  private token?: string
 
   constructor(config: CombinatorConfig) {
     this.baseURL = config.baseURL.replace(/\/$/, '')
+    this.token = config.token
   }
 
   async request(
@@ -18,9 +20,13 @@ export class Combinator {
     headers?: HeadersInit,
     body?: BodyInit
   ): Promise<Response> {
+    const finalHeaders = new Headers(headers)
+    if (this.token) {
+      finalHeaders.set('Authorization', `Bearer ${this.token}`)
+    }
     const response = await fetch(`${this.baseURL}${path}`, {
       method,
-      headers: headers,
+      headers: finalHeaders,
       body: body,
     })
     return response
@@ -1,5 +1,7 @@
 export interface CombinatorConfig {
   baseURL: string
+  // bearer API key, sent as `Authorization: Bearer <token>`
+  token?: string
 }
 
 export interface RDBOptions {